@@ -0,0 +1,131 @@
+// Package collision resolves overlaps between agents, and between agents and
+// features.
+package collision
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/filters"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+)
+
+// IsResolvable checks if two agents are overlapping and should be pushed apart,
+// i.e. they are on the same terrain layer and neither agent may run over the
+// other.
+func IsResolvable(a agent.RO, b agent.RO) bool {
+	return filters.AgentIsColliding(a, b) && !filters.AgentIsSquishable(a, b) && !filters.AgentIsSquishable(b, a)
+}
+
+// SeparateAgents calculates the displacement of each agent needed to separate
+// two overlapping agents. The displacement is split between the agents
+// inversely proportional to their masses, i.e. the heavier agent moves less.
+// If either mass is not positive, the displacement is split equally. Agents
+// which are exactly on top of one another are separated along the X-axis.
+func SeparateAgents(a agent.RO, b agent.RO) (vector.V, vector.V) {
+	u := vector.Sub(a.Position(), b.Position())
+	d := vector.Magnitude(u)
+
+	overlap := a.Radius() + b.Radius() - d
+	if overlap <= 0 {
+		return vector.V{0, 0}, vector.V{0, 0}
+	}
+
+	n := vector.V{1, 0}
+	if d > 0 {
+		n = vector.Scale(1/d, u)
+	}
+
+	wa, wb := 0.5, 0.5
+	if ma, mb := a.Mass(), b.Mass(); ma > 0 && mb > 0 {
+		wa, wb = mb/(ma+mb), ma/(ma+mb)
+	}
+	return vector.Scale(overlap*wa, n), vector.Scale(-overlap*wb, n)
+}
+
+// SeparateFeature calculates the displacement needed to push the agent out of
+// the feature. If the agent center lies outside the feature, the agent is
+// pushed along the normal of the closest edge or corner, as given by
+// hyperrectangle.Normal; otherwise, the agent is pushed out through the
+// closest edge.
+func SeparateFeature(a agent.RO, f feature.RO) vector.V {
+	p, r := a.Position(), a.Radius()
+	aabb := f.AABB()
+
+	if !aabb.In(p) {
+		d, n := dhr.Normal(aabb, p)
+		if d >= r {
+			return vector.V{0, 0}
+		}
+		return vector.Scale(r-d, n)
+	}
+
+	// Find the closest edge to the agent center.
+	edges := []struct {
+		d float64
+		n vector.V
+	}{
+		{d: aabb.Max().Y() - p.Y(), n: vector.V{0, 1}},
+		{d: aabb.Max().X() - p.X(), n: vector.V{1, 0}},
+		{d: p.Y() - aabb.Min().Y(), n: vector.V{0, -1}},
+		{d: p.X() - aabb.Min().X(), n: vector.V{-1, 0}},
+	}
+	d, n := math.Inf(1), vector.V{0, 0}
+	for _, e := range edges {
+		if e.d < d {
+			d, n = e.d, e.n
+		}
+	}
+	return vector.Scale(d+r, n)
+}
+
+// Resolve pushes apart all overlapping agents, and pushes all agents out of
+// overlapping features, in a single pass. Agents on different terrain layers,
+// and agents which may be run over by the other (see
+// filters.AgentIsSquishable), are not separated.
+//
+// All displacements are calculated from the current state of the DB and then
+// summed per agent, before the new positions are committed in agent ID order.
+// Resolve therefore does not guarantee all overlaps are resolved after a
+// single call, e.g. if an agent is pushed into a third agent.
+//
+// Resolve returns the IDs of the agents which were moved, in ascending order.
+//
+// Resolve mutates the BVH and must be called serially.
+func Resolve(db *database.DB) []id.ID {
+	deltas := map[id.ID]vector.M{}
+	positions := map[id.ID]vector.V{}
+	add := func(a agent.RO, v vector.V) {
+		if _, ok := deltas[a.ID()]; !ok {
+			deltas[a.ID()] = vector.M{0, 0}
+			positions[a.ID()] = a.Position()
+		}
+		deltas[a.ID()].Add(v)
+	}
+
+	for _, p := range db.AgentPairs(IsResolvable) {
+		u, v := SeparateAgents(p.A, p.B)
+		add(p.A, u)
+		add(p.B, v)
+	}
+	for _, p := range db.AgentFeaturePairs(filters.AgentIsCollidingWithFeature) {
+		add(p.Agent, SeparateFeature(p.Agent, p.Feature))
+	}
+
+	xs := make([]id.ID, 0, len(deltas))
+	for x := range deltas {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	for _, x := range xs {
+		db.SetAgentPosition(x, vector.Add(positions[x], deltas[x].V()))
+	}
+	return xs
+}
@@ -0,0 +1,160 @@
+package collision
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/agent/mock"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	fmock "github.com/downflux/go-database/feature/mock"
+)
+
+func TestSeparateAgents(t *testing.T) {
+	type config struct {
+		name  string
+		a     agent.RO
+		b     agent.RO
+		wantA vector.V
+		wantB vector.V
+	}
+
+	configs := []config{
+		{
+			name:  "Disjoint",
+			a:     mock.New(1, agent.O{Position: vector.V{0, 0}}),
+			b:     mock.New(2, agent.O{Position: vector.V{3, 0}}),
+			wantA: vector.V{0, 0},
+			wantB: vector.V{0, 0},
+		},
+		{
+			name:  "EqualMass",
+			a:     mock.New(1, agent.O{Position: vector.V{0, 0}}),
+			b:     mock.New(2, agent.O{Position: vector.V{1, 0}}),
+			wantA: vector.V{-0.5, 0},
+			wantB: vector.V{0.5, 0},
+		},
+		{
+			name:  "Heavy",
+			a:     mock.New(1, agent.O{Position: vector.V{0, 0}, Mass: 3}),
+			b:     mock.New(2, agent.O{Position: vector.V{0, 1}, Mass: 1}),
+			wantA: vector.V{0, -0.25},
+			wantB: vector.V{0, 0.75},
+		},
+		{
+			// Non-positive masses fall back to an equal split.
+			name:  "NegativeMass",
+			a:     mock.New(1, agent.O{Position: vector.V{0, 0}, Mass: -1}),
+			b:     mock.New(2, agent.O{Position: vector.V{0, 1}, Mass: 1}),
+			wantA: vector.V{0, -0.5},
+			wantB: vector.V{0, 0.5},
+		},
+		{
+			name:  "Coincident",
+			a:     mock.New(1, agent.O{Position: vector.V{0, 0}}),
+			b:     mock.New(2, agent.O{Position: vector.V{0, 0}}),
+			wantA: vector.V{1, 0},
+			wantB: vector.V{-1, 0},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if gotA, gotB := SeparateAgents(c.a, c.b); !vector.Within(gotA, c.wantA) || !vector.Within(gotB, c.wantB) {
+				t.Errorf("SeparateAgents() = %v, %v, want = %v, %v", gotA, gotB, c.wantA, c.wantB)
+			}
+		})
+	}
+}
+
+func TestSeparateFeature(t *testing.T) {
+	f := fmock.New(1, feature.O{
+		AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}),
+	})
+
+	type config struct {
+		name string
+		a    agent.RO
+		want vector.V
+	}
+
+	configs := []config{
+		{
+			name: "Disjoint",
+			a:    mock.New(1, agent.O{Position: vector.V{-2, 5}}),
+			want: vector.V{0, 0},
+		},
+		{
+			name: "Edge",
+			a:    mock.New(1, agent.O{Position: vector.V{-0.5, 5}}),
+			want: vector.V{-0.5, 0},
+		},
+		{
+			name: "Inside",
+			a:    mock.New(1, agent.O{Position: vector.V{5, 9}}),
+			want: vector.V{0, 2},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := SeparateFeature(c.a, f); !vector.Within(got, c.want) {
+				t.Errorf("SeparateFeature() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	db := database.New(database.DefaultO)
+
+	o := agent.O{
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		Size:           size.FSmall,
+		Flags:          flags.FTerrainAccessibleLand | flags.FTerrainLand,
+	}
+
+	o.Position = vector.V{0, 0}
+	a := db.InsertAgent(o)
+	o.Position = vector.V{1, 0}
+	b := db.InsertAgent(o)
+
+	// Aircraft do not collide with land agents.
+	o.Position = vector.V{0.5, 0}
+	o.Flags = flags.FTerrainAccessibleAir | flags.FTerrainAir
+	c := db.InsertAgent(o)
+
+	db.InsertFeature(feature.O{
+		AABB: *hyperrectangle.New(vector.V{-10, -10}, vector.V{-0.75, 10}),
+	})
+
+	got := Resolve(db)
+	if want := []id.ID{a.ID(), b.ID()}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Resolve() = %v, want = %v", got, want)
+	}
+
+	// The agent a is pushed left by b by 0.5 units, and pushed right by
+	// the wall by 0.25 units.
+	if want := (vector.V{-0.25, 0}); !vector.Within(a.Position(), want) {
+		t.Errorf("Position() = %v, want = %v", a.Position(), want)
+	}
+	if want := (vector.V{1.5, 0}); !vector.Within(b.Position(), want) {
+		t.Errorf("Position() = %v, want = %v", b.Position(), want)
+	}
+	if want := (vector.V{0.5, 0}); !vector.Within(c.Position(), want) {
+		t.Errorf("Position() = %v, want = %v", c.Position(), want)
+	}
+}
@@ -0,0 +1,354 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-database/internal/agent"
+	"github.com/downflux/go-database/internal/feature"
+	"github.com/downflux/go-database/internal/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+const (
+	// magic prefixes all serialized DBs.
+	magic = "DFDB"
+
+	// version is the current encoding version, and must be incremented on
+	// any change to the layout below.
+	//
+	// The version 1 layout is, with all fixed-width values in
+	// little-endian byte order,
+	//
+	//  header      := magic version:u16 counter:u64
+	//  db          := header agents features projectiles
+	//  agents      := n:uvarint agent{n}
+	//  features    := n:uvarint feature{n}
+	//  projectiles := n:uvarint projectile{n}
+	//
+	//  agent       := id:u64 position:v targetPosition:v velocity:v
+	//                 targetVelocity:v heading:v radius:f64 mass:f64
+	//                 maxVelocity:f64 maxAngularVelocity:f64
+	//                 maxAcceleration:f64 flags:uvarint size:uvarint
+	//                 team:u8 move:uvarint
+	//  feature     := id:u64 min:v max:v flags:uvarint team:u8
+	//  projectile  := id:u64 position:v targetPosition:v velocity:v
+	//                 targetVelocity:v heading:v radius:f64 flags:uvarint
+	//                 team:u8
+	//  v           := f64 f64
+	//
+	// Entities of each kind are written in ascending ID order, so equal DBs
+	// always serialize to the same bytes.
+	version uint16 = 1
+)
+
+// MarshalBinary serializes all agents, features and projectiles in the DB,
+// along with the ID counter. User-registered tables and component stores are
+// not serialized. For a double-buffered DB, the back buffer is serialized.
+//
+// MarshalBinary is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) MarshalBinary() ([]byte, error) {
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	e := &encoder{}
+
+	e.buf = append(e.buf, magic...)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, version)
+	e.u64(db.count())
+
+	e.uvarint(uint64(db.agents.Len()))
+	for _, x := range db.agents.IDs() {
+		a := db.agents.At(x)
+
+		e.u64(uint64(a.ID()))
+		e.vector(a.Position())
+		e.vector(a.TargetPosition())
+		e.vector(a.Velocity())
+		e.vector(a.TargetVelocity())
+		e.vector(vector.V(a.Heading()))
+		e.f64(a.Radius())
+		e.f64(a.Mass())
+		e.f64(a.MaxVelocity())
+		e.f64(a.MaxAngularVelocity())
+		e.f64(a.MaxAcceleration())
+		e.uvarint(uint64(a.Flags()))
+		e.uvarint(uint64(a.Size()))
+		e.buf = append(e.buf, byte(a.Team()))
+		e.uvarint(uint64(a.MoveMode()))
+	}
+
+	e.uvarint(uint64(db.features.Len()))
+	for _, x := range db.features.IDs() {
+		f := db.features.At(x)
+
+		e.u64(uint64(f.ID()))
+		e.vector(f.AABB().Min())
+		e.vector(f.AABB().Max())
+		e.uvarint(uint64(f.Flags()))
+		e.buf = append(e.buf, byte(f.Team()))
+	}
+
+	e.uvarint(uint64(db.projectiles.Len()))
+	for _, x := range db.projectiles.IDs() {
+		p := db.projectiles.At(x)
+
+		e.u64(uint64(p.ID()))
+		e.vector(p.Position())
+		e.vector(p.TargetPosition())
+		e.vector(p.Velocity())
+		e.vector(p.TargetVelocity())
+		e.vector(vector.V(p.Heading()))
+		e.f64(p.Radius())
+		e.uvarint(uint64(p.Flags()))
+		e.buf = append(e.buf, byte(p.Team()))
+	}
+
+	return e.buf, nil
+}
+
+// Unmarshal creates a new DB with the input options from data previously
+// serialized by DB.MarshalBinary. All entities keep their original IDs, and the
+// BVHs are rebuilt from the entity positions.
+//
+// Unmarshal returns an error wrapping ErrCorrupt if the data is malformed or
+// was written with an unsupported version.
+func Unmarshal(o O, data []byte) (*DB, error) {
+	d := &decoder{buf: data}
+
+	if m := d.next(len(magic)); d.err == nil && string(m) != magic {
+		return nil, fmt.Errorf("invalid header %q: %w", m, errors.ErrCorrupt)
+	}
+	if v := d.u16(); d.err == nil && v != version {
+		return nil, fmt.Errorf("unsupported version %v: %w", v, errors.ErrCorrupt)
+	}
+	counter := d.u64()
+
+	db := New(o)
+
+	for i, n := uint64(0), d.uvarint(); i < n && d.err == nil; i++ {
+		x := d.id(KindAgent, counter)
+		ao := agent.O{
+			Position:           d.vector(),
+			TargetPosition:     d.vector(),
+			Velocity:           d.vector(),
+			TargetVelocity:     d.vector(),
+			Heading:            polar.V(d.vector()),
+			Radius:             d.f64(),
+			Mass:               d.f64(),
+			MaxVelocity:        d.f64(),
+			MaxAngularVelocity: d.f64(),
+			MaxAcceleration:    d.f64(),
+			Flags:              flags.F(d.uvarint()),
+			Size:               size.F(d.uvarint()),
+			Team:               team.F(d.u8()),
+			Move:               move.F(d.uvarint()),
+		}
+		if d.err != nil {
+			break
+		}
+		if !agent.Validate(ao) || !move.Validate(ao.Move) || !bounded(ao.Position, ao.Radius) {
+			return nil, fmt.Errorf("invalid agent %v: %w", x, errors.ErrCorrupt)
+		}
+
+		a := agent.New(ao)
+		a.SetID(x)
+		if err := db.agents.Insert(a); err != nil {
+			return nil, fmt.Errorf("cannot insert agent %v: %v: %w", x, err, errors.ErrCorrupt)
+		}
+	}
+
+	for i, n := uint64(0), d.uvarint(); i < n && d.err == nil; i++ {
+		x := d.id(KindFeature, counter)
+		min, max := d.vector(), d.vector()
+		fo := feature.O{
+			Flags: flags.F(d.uvarint()),
+			Team:  team.F(d.u8()),
+		}
+		if d.err != nil {
+			break
+		}
+		if !feature.Validate(fo) || min.X() > max.X() || min.Y() > max.Y() {
+			return nil, fmt.Errorf("invalid feature %v: %w", x, errors.ErrCorrupt)
+		}
+		fo.AABB = *hyperrectangle.New(min, max)
+
+		f := feature.New(fo)
+		f.SetID(x)
+		if err := db.features.Insert(f); err != nil {
+			return nil, fmt.Errorf("cannot insert feature %v: %v: %w", x, err, errors.ErrCorrupt)
+		}
+	}
+
+	for i, n := uint64(0), d.uvarint(); i < n && d.err == nil; i++ {
+		x := d.id(KindProjectile, counter)
+		po := projectile.O{
+			Position:       d.vector(),
+			TargetPosition: d.vector(),
+			Velocity:       d.vector(),
+			TargetVelocity: d.vector(),
+			Heading:        polar.V(d.vector()),
+			Radius:         d.f64(),
+			Flags:          flags.F(d.uvarint()),
+			Team:           team.F(d.u8()),
+		}
+		if d.err != nil {
+			break
+		}
+		if !projectile.Validate(po) || !bounded(po.Position, po.Radius) {
+			return nil, fmt.Errorf("invalid projectile %v: %w", x, errors.ErrCorrupt)
+		}
+
+		p := projectile.New(po)
+		p.SetID(x)
+		if err := db.projectiles.Insert(p); err != nil {
+			return nil, fmt.Errorf("cannot insert projectile %v: %v: %w", x, err, errors.ErrCorrupt)
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.buf) > 0 {
+		return nil, fmt.Errorf("unexpected %v trailing bytes: %w", len(d.buf), errors.ErrCorrupt)
+	}
+
+	db.counter = counter
+
+	// The front buffer was taken when the DB was empty.
+	db.Swap()
+
+	return db, nil
+}
+
+// bounded checks the circle with the input center and radius has a positive
+// radius and a finite AABB.
+func bounded(p vector.V, r float64) bool {
+	if !(r > 0) {
+		return false
+	}
+	for _, v := range []float64{p.X() - r, p.Y() - r, p.X() + r, p.Y() + r} {
+		if math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// encoder appends little-endian values to a buffer.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u64(v uint64)     { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+func (e *encoder) uvarint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+func (e *encoder) f64(v float64)    { e.u64(math.Float64bits(v)) }
+
+func (e *encoder) vector(v vector.V) {
+	e.f64(v.X())
+	e.f64(v.Y())
+}
+
+// decoder consumes little-endian values from a buffer. The first error is
+// recorded in err, after which all reads return zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = fmt.Errorf("unexpected end of data: %w", errors.ErrCorrupt)
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = fmt.Errorf("invalid varint: %w", errors.ErrCorrupt)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// f64 reads a float, and rejects NaN and infinite values, which are never
+// written by a valid DB.
+func (d *decoder) f64() float64 {
+	v := math.Float64frombits(d.u64())
+	if d.err != nil {
+		return 0
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		d.err = fmt.Errorf("invalid float %v: %w", v, errors.ErrCorrupt)
+		return 0
+	}
+	return v
+}
+
+func (d *decoder) vector() vector.V {
+	x := d.f64()
+	y := d.f64()
+	return vector.V{x, y}
+}
+
+// id reads an entity ID, and checks it is of the expected kind and was
+// allocated before the input counter value.
+func (d *decoder) id(k Kind, counter uint64) id.ID {
+	x := id.ID(d.u64())
+	if d.err != nil {
+		return 0
+	}
+	if KindOf(x) != k || count(x) >= counter {
+		d.err = fmt.Errorf("invalid %v ID %v: %w", k, x, errors.ErrCorrupt)
+		return 0
+	}
+	return x
+}
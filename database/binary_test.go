@@ -0,0 +1,231 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+)
+
+func newBinaryDB() *DB {
+	db := New(DefaultO)
+	db.InsertAgent(roagent.O{
+		Position:           vector.V{1, 2},
+		TargetPosition:     vector.V{3, 4},
+		Velocity:           vector.V{5, 6},
+		TargetVelocity:     vector.V{7, 8},
+		Heading:            polar.V{1, 0.5},
+		Radius:             1.5,
+		Mass:               2.5,
+		MaxVelocity:        10,
+		MaxAngularVelocity: 11,
+		MaxAcceleration:    12,
+		Flags:              flags.FTerrainAccessibleLand | flags.FTerrainLand,
+		Size:               size.FMedium,
+		Team:               team.F(3),
+		Move:               move.FArrival | move.FFlocking,
+	})
+	x := db.InsertAgent(newSnapshotAgent(vector.V{100, 100})).ID()
+	db.InsertFeature(rofeature.O{
+		AABB:  *hyperrectangle.New(vector.V{20, 20}, vector.V{30, 40}),
+		Flags: flags.FTerrainAccessibleLand | flags.FTerrainLand,
+		Team:  team.F(2),
+	})
+	db.InsertProjectile(roprojectile.O{
+		Position:       vector.V{-1, -2},
+		TargetPosition: vector.V{-3, -4},
+		Velocity:       vector.V{-5, -6},
+		TargetVelocity: vector.V{-7, -8},
+		Heading:        polar.V{1, -0.5},
+		Radius:         0.25,
+		Flags:          flags.FTerrainAccessibleAir | flags.FTerrainAir,
+		Team:           team.F(1),
+	})
+
+	// Deleted entities leave gaps in the ID space which must be preserved.
+	db.DeleteAgent(x)
+	return db
+}
+
+// dump returns a string representation of all fields of all entities in the
+// DB.
+func dump(db *DB) string {
+	var b bytes.Buffer
+	db.ForEachAgent(func(a roagent.RO) bool {
+		fmt.Fprintln(&b, a.ID(), a.Position(), a.TargetPosition(), a.Velocity(), a.TargetVelocity(), a.Heading(), a.Radius(), a.Mass(), a.MaxVelocity(), a.MaxAngularVelocity(), a.MaxAcceleration(), a.Flags(), a.Size(), a.Team(), a.MoveMode())
+		return true
+	})
+	db.ForEachFeature(func(f rofeature.RO) bool {
+		fmt.Fprintln(&b, f.ID(), f.AABB(), f.Flags(), f.Team())
+		return true
+	})
+	db.ForEachProjectile(func(p roprojectile.RO) bool {
+		fmt.Fprintln(&b, p.ID(), p.Position(), p.TargetPosition(), p.Velocity(), p.TargetVelocity(), p.Heading(), p.Radius(), p.Flags(), p.Team())
+		return true
+	})
+	return b.String()
+}
+
+func TestMarshalBinary(t *testing.T) {
+	type config struct {
+		name string
+		o    O
+	}
+
+	configs := []config{
+		{name: "Default", o: DefaultO},
+		{name: "Ordered", o: O{LeafSize: 8, Tolerance: 1.15, Ordered: true}},
+		{name: "DoubleBuffered", o: O{LeafSize: 8, Tolerance: 1.15, DoubleBuffered: true}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := newBinaryDB()
+
+			data, err := db.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() = %v, want = %v", err, nil)
+			}
+
+			got, err := Unmarshal(c.o, data)
+			if err != nil {
+				t.Fatalf("Unmarshal() = %v, want = %v", err, nil)
+			}
+
+			if dump(got) != dump(db) {
+				t.Errorf("Unmarshal() = %v, want = %v", dump(got), dump(db))
+			}
+			if got.count() != db.count() {
+				t.Errorf("count() = %v, want = %v", got.count(), db.count())
+			}
+
+			// Check the BVH was rebuilt.
+			if qs := got.QueryFeatures(*hyperrectangle.New(vector.V{25, 35}, vector.V{26, 36}), func(rofeature.RO) bool { return true }); len(qs) != 1 {
+				t.Errorf("QueryFeatures() = %v, want = %v", len(qs), 1)
+			}
+
+			// Check the serialization is deterministic.
+			if redata, err := got.MarshalBinary(); err != nil || !bytes.Equal(redata, data) {
+				t.Errorf("MarshalBinary() = %v, %v, want = %v, %v", redata, err, data, nil)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCorrupt(t *testing.T) {
+	data, err := newBinaryDB().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() = %v, want = %v", err, nil)
+	}
+
+	header := len(magic) + 2 + 8
+
+	// radius is the offset of the radius of the first agent, which follows
+	// the agent count, ID and five vectors.
+	radius := header + 1 + 8 + 5*16
+
+	type config struct {
+		name string
+		data []byte
+	}
+
+	configs := []config{
+		{name: "Empty", data: nil},
+		{name: "Magic", data: append([]byte("XXXX"), data[len(magic):]...)},
+		{
+			name: "Version",
+			data: func() []byte {
+				d := append([]byte{}, data...)
+				binary.LittleEndian.PutUint16(d[len(magic):], version+1)
+				return d
+			}(),
+		},
+		{name: "Truncated", data: data[:len(data)-1]},
+		{name: "Trailing", data: append(append([]byte{}, data...), 0)},
+		{
+			// The counter must be larger than all entity IDs.
+			name: "Counter",
+			data: func() []byte {
+				d := append([]byte{}, data...)
+				binary.LittleEndian.PutUint64(d[len(magic)+2:], 0)
+				return d
+			}(),
+		},
+		{
+			// The first agent ID must encode the agent kind.
+			name: "Kind",
+			data: func() []byte {
+				d := append([]byte{}, data...)
+				d[header+1+7] = byte(KindProjectile)
+				return d
+			}(),
+		},
+		{
+			// The first agent radius must be positive.
+			name: "Radius",
+			data: func() []byte {
+				d := append([]byte{}, data...)
+				binary.LittleEndian.PutUint64(d[radius:], math.Float64bits(-1))
+				return d
+			}(),
+		},
+		{
+			name: "NaN",
+			data: func() []byte {
+				d := append([]byte{}, data...)
+				binary.LittleEndian.PutUint64(d[radius:], math.Float64bits(math.NaN()))
+				return d
+			}(),
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Unmarshal(DefaultO, c.data); !errors.Is(err, dberrors.ErrCorrupt) {
+				t.Errorf("Unmarshal() = %v, want = %v", err, dberrors.ErrCorrupt)
+			}
+		})
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	data, err := newBinaryDB().MarshalBinary()
+	if err != nil {
+		f.Fatalf("MarshalBinary() = %v, want = %v", err, nil)
+	}
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		db, err := Unmarshal(DefaultO, data)
+		if err != nil {
+			if !errors.Is(err, dberrors.ErrCorrupt) {
+				t.Errorf("Unmarshal() = %v, want = %v", err, dberrors.ErrCorrupt)
+			}
+			return
+		}
+
+		// Valid data must round-trip.
+		redata, err := db.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() = %v, want = %v", err, nil)
+		}
+		if got, err := Unmarshal(DefaultO, redata); err != nil || dump(got) != dump(db) {
+			t.Errorf("Unmarshal() = %v, %v, want = %v, %v", got, err, dump(db), nil)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package database
+
+// Swap publishes all mutations made to a double-buffered DB since the last
+// call to Swap, i.e. the back buffer becomes the new front buffer, and all
+// subsequent reads observe the new state, including the updated agent,
+// feature and projectile BVHs. Swap is a no-op if the DB was not created with
+// O.DoubleBuffered.
+//
+// Swap mutates the DB and must be called serially. In particular, Swap must
+// not be called from within a ForEach* callback.
+func (db *DB) Swap() {
+	if !db.o.DoubleBuffered {
+		return
+	}
+
+	db.frontL.Lock()
+	defer db.frontL.Unlock()
+
+	s := db.Snapshot()
+	db.front.Release()
+	db.front = s
+}
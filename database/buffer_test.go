@@ -0,0 +1,178 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+)
+
+func TestSwap(t *testing.T) {
+	type config struct {
+		name string
+		o    O
+	}
+
+	configs := []config{
+		{name: "Default", o: O{LeafSize: 8, Tolerance: 1.15, DoubleBuffered: true}},
+		{name: "Concurrent", o: O{LeafSize: 8, Tolerance: 1.15, DoubleBuffered: true, Concurrent: true}},
+		{name: "Ordered", o: O{LeafSize: 8, Tolerance: 1.15, DoubleBuffered: true, Ordered: true}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := New(c.o)
+
+			x := db.InsertAgent(newSnapshotAgent(vector.V{0, 0})).ID()
+			if _, err := db.GetAgent(x); !errors.Is(err, dberrors.ErrNotFound) {
+				t.Errorf("GetAgent() = %v, want = %v", err, dberrors.ErrNotFound)
+			}
+
+			db.Swap()
+			if _, err := db.GetAgent(x); err != nil {
+				t.Fatalf("GetAgent() = %v, want = %v", err, nil)
+			}
+
+			y := db.InsertAgent(newSnapshotAgent(vector.V{10, 0})).ID()
+			db.Swap()
+
+			// Setters may be called from within the iteration, and
+			// are not visible until the next Swap.
+			db.ForEachAgent(func(a roagent.RO) bool {
+				db.SetAgentPosition(a.ID(), vector.Add(a.Position(), vector.V{0, 10}))
+				return true
+			})
+
+			for z, want := range map[id.ID]vector.V{
+				x: vector.V{0, 0},
+				y: vector.V{10, 0},
+			} {
+				if got := db.GetAgentOrDie(z).Position(); !vector.Within(got, want) {
+					t.Errorf("Position() = %v, want = %v", got, want)
+				}
+			}
+			if got := db.QueryAgents(*hyperrectangle.New(vector.V{-1, 9}, vector.V{11, 11}), func(roagent.RO) bool { return true }); len(got) != 0 {
+				t.Errorf("QueryAgents() = %v, want = %v", got, []roagent.RO{})
+			}
+
+			db.DeleteAgent(y)
+			if _, err := db.GetAgent(y); err != nil {
+				t.Errorf("GetAgent() = %v, want = %v", err, nil)
+			}
+
+			db.Swap()
+
+			if want := (vector.V{0, 10}); !vector.Within(db.GetAgentOrDie(x).Position(), want) {
+				t.Errorf("Position() = %v, want = %v", db.GetAgentOrDie(x).Position(), want)
+			}
+			if _, err := db.GetAgent(y); !errors.Is(err, dberrors.ErrNotFound) {
+				t.Errorf("GetAgent() = %v, want = %v", err, dberrors.ErrNotFound)
+			}
+
+			var got []id.ID
+			for _, a := range db.QueryAgents(*hyperrectangle.New(vector.V{-1, 9}, vector.V{11, 11}), func(roagent.RO) bool { return true }) {
+				got = append(got, a.ID())
+			}
+			if want := []id.ID{x}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("QueryAgents() = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSwapUnbuffered(t *testing.T) {
+	db := New(DefaultO)
+	x := db.InsertAgent(newSnapshotAgent(vector.V{0, 0})).ID()
+
+	db.Swap()
+	db.SetAgentPosition(x, vector.V{10, 10})
+
+	if got, want := db.GetAgentOrDie(x).Position(), (vector.V{10, 10}); !vector.Within(got, want) {
+		t.Errorf("Position() = %v, want = %v", got, want)
+	}
+	if got := len(db.snapshots); got != 0 {
+		t.Errorf("len(snapshots) = %v, want = %v", got, 0)
+	}
+}
+
+func TestSwapSpatial(t *testing.T) {
+	db := New(O{LeafSize: 8, Tolerance: 1.15, DoubleBuffered: true})
+
+	x := db.InsertAgent(newSnapshotAgent(vector.V{0, 0})).ID()
+	y := db.InsertAgent(newSnapshotAgent(vector.V{1, 0})).ID()
+	db.Swap()
+
+	// Move y away from x and far along the ray in the back buffer only.
+	db.SetAgentPosition(y, vector.V{100, 0})
+	z := db.InsertAgent(newSnapshotAgent(vector.V{0, 50})).ID()
+
+	all := func(roagent.RO) bool { return true }
+
+	if got := db.NearestAgents(vector.V{100, 0}, 1, all); len(got) != 1 || got[0].ID() != y {
+		t.Errorf("NearestAgents() = %v, want = [%v]", got, y)
+	}
+	if got := db.NearestAgents(vector.V{0, 50}, 3, all); len(got) != 2 {
+		t.Errorf("NearestAgents() = %v, want = %v agents", got, 2)
+	}
+	if hit, ok := db.RaycastAgents(vector.V{-10, 0}, vector.V{1, 0}, 1000, func(a roagent.RO) bool { return a.ID() != x }); !ok || hit.Agent.ID() != y || hit.D > 20 {
+		t.Errorf("RaycastAgents() = %v, %v, want = %v, %v", hit, ok, y, true)
+	}
+	if got := db.AgentPairs(func(roagent.RO, roagent.RO) bool { return true }); len(got) != 1 {
+		t.Errorf("AgentPairs() = %v, want = %v pairs", got, 1)
+	}
+
+	db.Swap()
+
+	if got := db.NearestAgents(vector.V{0, 50}, 1, all); len(got) != 1 || got[0].ID() != z {
+		t.Errorf("NearestAgents() = %v, want = [%v]", got, z)
+	}
+	if got := db.AgentPairs(func(roagent.RO, roagent.RO) bool { return true }); len(got) != 0 {
+		t.Errorf("AgentPairs() = %v, want = %v pairs", got, 0)
+	}
+}
+
+func TestSwapSweep(t *testing.T) {
+	db := New(O{LeafSize: 8, Tolerance: 1.15, DoubleBuffered: true})
+
+	a := db.InsertAgent(newSnapshotAgent(vector.V{30, 0})).ID()
+	p := db.InsertProjectile(roprojectile.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{100, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         0.5,
+	}).ID()
+	db.Swap()
+
+	// Move the agent out of the way, stop the projectile and add a wall in
+	// front of it in the back buffer only.
+	db.SetAgentPosition(a, vector.V{0, 50})
+	db.SetProjectileVelocity(p, vector.V{0, 0})
+	db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{10, -10}, vector.V{10.1, 10}),
+	})
+
+	agents := func(roprojectile.RO, roagent.RO) bool { return true }
+	features := func(roprojectile.RO, rofeature.RO) bool { return true }
+
+	if got, ok, err := db.SweepProjectile(p, 1, agents, features); err != nil || !ok || got.Agent == nil || got.Agent.ID() != a || !epsilon.Within(got.T, 0.285) {
+		t.Errorf("SweepProjectile() = %v, %v, %v, want agent %v at T = 0.285", got, ok, err, a)
+	}
+
+	db.Swap()
+
+	if got, ok, err := db.SweepProjectile(p, 1, agents, features); err != nil || ok {
+		t.Errorf("SweepProjectile() = %v, %v, %v, want = _, false, nil", got, ok, err)
+	}
+}
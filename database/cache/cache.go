@@ -1,20 +1,21 @@
 package cache
 
 import (
-	"fmt"
+	"context"
 
-	"github.com/downflux/go-bvh/container"
 	"github.com/downflux/go-bvh/container/bruteforce"
 	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database/table"
 	"github.com/downflux/go-database/feature"
 	"github.com/downflux/go-database/projectile"
 	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
 
 	roagent "github.com/downflux/go-database/agent"
 	rofeature "github.com/downflux/go-database/feature"
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
 	roprojectile "github.com/downflux/go-database/projectile"
-	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
 )
 
 type O struct {
@@ -24,69 +25,79 @@ type O struct {
 }
 
 type DB struct {
-	agents      map[id.ID]agent.RO
-	features    map[id.ID]feature.RO
-	projectiles map[id.ID]projectile.RO
-
-	agentsBVH   container.C
-	featuresBVH container.C
+	agents      *table.T[agent.RO]
+	features    *table.T[feature.RO]
+	projectiles *table.T[projectile.RO]
 }
 
 func New(o O) *DB {
 	db := &DB{
-		agents:      make(map[id.ID]agent.RO, len(o.Agents)),
-		features:    make(map[id.ID]feature.RO, len(o.Features)),
-		projectiles: make(map[id.ID]projectile.RO, len(o.Projectiles)),
-
-		agentsBVH:   bruteforce.New(),
-		featuresBVH: bruteforce.New(),
+		agents:      table.New[agent.RO](table.O{Name: "agent", Index: bruteforce.New()}),
+		features:    table.New[feature.RO](table.O{Name: "feature", Index: bruteforce.New()}),
+		projectiles: table.New[projectile.RO](table.O{Name: "projectile", Index: bruteforce.New()}),
 	}
 
 	for _, a := range o.Agents {
-		db.agents[a.ID()] = a
-		db.agentsBVH.Insert(a.ID(), hnd.R(a.AABB()))
+		db.agents.Insert(a)
 	}
 
 	for _, f := range o.Features {
-		db.features[f.ID()] = f
-		db.featuresBVH.Insert(f.ID(), hnd.R(f.AABB()))
+		db.features.Insert(f)
 	}
 
 	for _, p := range o.Projectiles {
-		db.projectiles[p.ID()] = p
+		db.projectiles.Insert(p)
 	}
 
 	return db
 }
 
+// GetAgent is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (db *DB) GetAgent(x id.ID) (roagent.RO, error) {
+	return db.agents.Get(x)
+}
+
+// GetFeature is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) GetFeature(x id.ID) (rofeature.RO, error) {
+	return db.features.Get(x)
+}
+
+// GetProjectile is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) GetProjectile(x id.ID) (roprojectile.RO, error) {
+	return db.projectiles.Get(x)
+}
+
 // GetAgentOrDie is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) GetAgentOrDie(x id.ID) roagent.RO {
-	if a, ok := db.agents[x]; !ok {
-		panic(fmt.Sprintf("cannot find agent %v", x))
-	} else {
-		return a
+	a, err := db.GetAgent(x)
+	if err != nil {
+		panic(err.Error())
 	}
+	return a
 }
 
 // GetFeatureOrDie is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) GetFeatureOrDie(x id.ID) rofeature.RO {
-	if a, ok := db.features[x]; !ok {
-		panic(fmt.Sprintf("cannot find feature %v", x))
-	} else {
-		return a
+	f, err := db.GetFeature(x)
+	if err != nil {
+		panic(err.Error())
 	}
+	return f
 }
 
 // GetProjectileOrDie is a read-only operation and may be called concurrently
 // with other read-only operations.
 func (db *DB) GetProjectileOrDie(x id.ID) roprojectile.RO {
-	if a, ok := db.projectiles[x]; !ok {
-		panic(fmt.Sprintf("cannot find projectile %v", x))
-	} else {
-		return a
+	p, err := db.GetProjectile(x)
+	if err != nil {
+		panic(err.Error())
 	}
+	return p
 }
 
 // ListAgents returns all agents in the DB. There are serveral use-cases for
@@ -97,30 +108,17 @@ func (db *DB) GetProjectileOrDie(x id.ID) roprojectile.RO {
 //     and create a proposal batch of changes. If these changes do not modify
 //     the BVH, they may be run in parallel. Changes to the BVH (e.g.
 //     SetAgentPosition) must be done serially.
-func (db *DB) ListAgents() <-chan roagent.RO {
-	ch := make(chan roagent.RO, 256)
-	go func(ch chan<- roagent.RO) {
-		defer close(ch)
-		for _, a := range db.agents {
-			ch <- a
-		}
-	}(ch)
-	return ch
-}
+//
+// The caller must drain the returned channel. Callers which may stop early
+// should use ListAgentsContext or ForEachAgent instead.
+func (db *DB) ListAgents() <-chan roagent.RO { return db.ListAgentsContext(context.Background()) }
 
 // ListFeatures returns all features in the DB. There are serveral use-cases for
 // this method which changes the invocation pattern.
 //
 // See ListAgents for more information.
 func (db *DB) ListFeatures() <-chan rofeature.RO {
-	ch := make(chan rofeature.RO, 256)
-	go func(ch chan<- rofeature.RO) {
-		defer close(ch)
-		for _, a := range db.features {
-			ch <- a
-		}
-	}(ch)
-	return ch
+	return db.ListFeaturesContext(context.Background())
 }
 
 // ListProjectiles returns all projectiles in the DB. There are serveral
@@ -128,54 +126,115 @@ func (db *DB) ListFeatures() <-chan rofeature.RO {
 //
 // See ListAgents for more information.
 func (db *DB) ListProjectiles() <-chan roprojectile.RO {
-	ch := make(chan roprojectile.RO, 256)
-	go func(ch chan<- roprojectile.RO) {
-		defer close(ch)
-		for _, a := range db.projectiles {
-			ch <- a
-		}
-	}(ch)
-	return ch
+	return db.ListProjectilesContext(context.Background())
 }
 
-// DeleteAgent mutates the DB and must be called serially.
-func (db *DB) DeleteAgent(x id.ID) {
-	if _, ok := db.agents[x]; !ok {
-		panic(fmt.Sprintf("cannot find agent %v", x))
-	}
+// ListAgentsContext returns all agents in the DB. The returned channel is
+// closed after all agents have been sent, or after the input context is
+// cancelled, whichever comes first.
+func (db *DB) ListAgentsContext(ctx context.Context) <-chan roagent.RO {
+	return db.agents.List(ctx)
+}
 
-	delete(db.agents, x)
-	if err := db.agentsBVH.Remove(x); err != nil {
-		panic(fmt.Sprintf("cannot delete agent: %v", err))
-	}
+// ListFeaturesContext returns all features in the DB, and stops early if the input
+// context is cancelled.
+func (db *DB) ListFeaturesContext(ctx context.Context) <-chan rofeature.RO {
+	return db.features.List(ctx)
+}
+
+// ListProjectilesContext returns all projectiles in the DB, and stops early if the input
+// context is cancelled.
+func (db *DB) ListProjectilesContext(ctx context.Context) <-chan roprojectile.RO {
+	return db.projectiles.List(ctx)
 }
 
+// ForEachAgent calls fn on each agent in the DB, and stops iterating
+// early if fn returns false.
+func (db *DB) ForEachAgent(fn func(a roagent.RO) bool) { db.agents.ForEach(fn) }
+
+// ForEachFeature calls fn on each feature in the DB, and stops iterating
+// early if fn returns false.
+func (db *DB) ForEachFeature(fn func(f rofeature.RO) bool) { db.features.ForEach(fn) }
+
+// ForEachProjectile calls fn on each projectile in the DB, and stops iterating
+// early if fn returns false.
+func (db *DB) ForEachProjectile(fn func(p roprojectile.RO) bool) { db.projectiles.ForEach(fn) }
+
+// TryDeleteAgent mutates the DB and must be called serially.
+func (db *DB) TryDeleteAgent(x id.ID) error { return db.agents.Delete(x) }
+
+// TryDeleteFeature mutates the DB and must be called serially.
+func (db *DB) TryDeleteFeature(x id.ID) error { return db.features.Delete(x) }
+
+// TryDeleteProjectile mutates the DB and must be called serially.
+func (db *DB) TryDeleteProjectile(x id.ID) error { return db.projectiles.Delete(x) }
+
+// DeleteAgent mutates the DB and must be called serially.
+func (db *DB) DeleteAgent(x id.ID) { die(db.TryDeleteAgent(x)) }
+
+// DeleteFeature mutates the DB and must be called serially.
+func (db *DB) DeleteFeature(x id.ID) { die(db.TryDeleteFeature(x)) }
+
+// DeleteProjectile mutates the DB and must be called serially.
+func (db *DB) DeleteProjectile(x id.ID) { die(db.TryDeleteProjectile(x)) }
+
 // QueryAgents is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) QueryAgents(q hyperrectangle.R, filter func(a roagent.RO) bool) []roagent.RO {
-	candidates := db.agentsBVH.BroadPhase(hnd.R(q))
-
-	results := make([]roagent.RO, 0, len(candidates))
-	for _, x := range candidates {
-		a := db.agents[x]
-		if filter(a) {
-			results = append(results, a)
-		}
-	}
-	return results
+	return db.agents.Query(q, filter)
 }
 
 // QueryFeatures is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) QueryFeatures(q hyperrectangle.R, filter func(a rofeature.RO) bool) []rofeature.RO {
-	candidates := db.featuresBVH.BroadPhase(hnd.R(q))
+	return db.features.Query(q, filter)
+}
+
+// QueryProjectiles is a read-only operation and may be called concurrently
+// with other read-only operations.
+func (db *DB) QueryProjectiles(q hyperrectangle.R, filter func(a roprojectile.RO) bool) []roprojectile.RO {
+	return db.projectiles.Query(q, filter)
+}
+
+// QueryAgentsInRadius returns all agents whose circle overlaps the circle of
+// radius r centered at p.
+//
+// QueryAgentsInRadius is a read-only operation and may be called concurrently
+// with other read-only operations.
+func (db *DB) QueryAgentsInRadius(p vector.V, r float64, filter func(a roagent.RO) bool) []roagent.RO {
+	return db.QueryAgents(bound(p, r), func(a roagent.RO) bool {
+		d := r + a.Radius()
+		if vector.SquaredMagnitude(vector.Sub(a.Position(), p)) > d*d {
+			return false
+		}
+		return filter(a)
+	})
+}
 
-	results := make([]rofeature.RO, 0, len(candidates))
-	for _, x := range candidates {
-		a := db.features[x]
-		if filter(a) {
-			results = append(results, a)
+// QueryFeaturesInRadius returns all features whose AABB overlaps the circle of
+// radius r centered at p.
+//
+// QueryFeaturesInRadius is a read-only operation and may be called
+// concurrently with other read-only operations.
+func (db *DB) QueryFeaturesInRadius(p vector.V, r float64, filter func(a rofeature.RO) bool) []rofeature.RO {
+	return db.QueryFeatures(bound(p, r), func(f rofeature.RO) bool {
+		if !dhr.IntersectCircle(f.AABB(), p, r) {
+			return false
 		}
+		return filter(f)
+	})
+}
+
+// bound returns the AABB of the circle of radius r centered at p.
+func bound(p vector.V, r float64) hyperrectangle.R {
+	return *hyperrectangle.New(
+		vector.V{p.X() - r, p.Y() - r},
+		vector.V{p.X() + r, p.Y() + r},
+	)
+}
+
+func die(err error) {
+	if err != nil {
+		panic(err.Error())
 	}
-	return results
 }
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/errors"
+)
+
+// detacher is implemented by component stores, and is called by the DB when
+// an entity is deleted.
+type detacher interface {
+	detach(x id.ID)
+}
+
+// C is a typed component store attached to a DB, e.g. to track the health of
+// agents without keeping a separate side table in sync with the DB.
+//
+// Entity IDs are unique across all entity types, so a single store may hold
+// components for agents, features and projectiles. Components are removed
+// automatically when the underlying entity is deleted from the DB.
+//
+// C may be used concurrently if the DB was created with O.Concurrent set.
+type C[T any] struct {
+	db *DB
+
+	l    rw
+	data map[id.ID]T
+}
+
+// Register creates a new component store for the DB. Each call returns a
+// distinct store, i.e. multiple stores with the same component type may be
+// registered.
+func Register[T any](db *DB) *C[T] {
+	c := &C[T]{
+		db:   db,
+		l:    newRW(db.o.Concurrent),
+		data: make(map[id.ID]T, 1024),
+	}
+
+	db.registryL.Lock()
+	defer db.registryL.Unlock()
+
+	db.components = append(db.components, c)
+	return c
+}
+
+// TryAttach sets the component of the input entity, overwriting any existing
+// value.
+//
+// TryAttach must not be called concurrently with the deletion of the same
+// entity.
+func (c *C[T]) TryAttach(x id.ID, v T) error {
+	if _, err := c.db.Kind(x); err != nil {
+		return fmt.Errorf("cannot attach component to entity %v: %w", x, errors.ErrNotFound)
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.data[x] = v
+	return nil
+}
+
+// Attach must not be called concurrently with the deletion of the same
+// entity.
+func (c *C[T]) Attach(x id.ID, v T) { die(c.TryAttach(x, v)) }
+
+// Detach removes the component of the input entity, if it exists.
+func (c *C[T]) Detach(x id.ID) { c.detach(x) }
+
+// Get returns the component of the input entity, and whether or not the
+// component exists.
+func (c *C[T]) Get(x id.ID) (T, bool) {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	v, ok := c.data[x]
+	return v, ok
+}
+
+// Has checks if the input entity has the component attached.
+func (c *C[T]) Has(x id.ID) bool {
+	_, ok := c.Get(x)
+	return ok
+}
+
+// Len returns the number of entities with the component attached.
+func (c *C[T]) Len() int {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	return len(c.data)
+}
+
+// IDs returns the IDs of all entities with the component attached, in
+// ascending order.
+func (c *C[T]) IDs() []id.ID {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	ids := make([]id.ID, 0, len(c.data))
+	for x := range c.data {
+		ids = append(ids, x)
+	}
+	table.SortIDs(ids)
+	return ids
+}
+
+// ForEach calls fn on each (ID, component) pair in ascending ID order until fn
+// returns false.
+//
+// fn must not mutate the store.
+func (c *C[T]) ForEach(fn func(x id.ID, v T) bool) {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	ids := make([]id.ID, 0, len(c.data))
+	for x := range c.data {
+		ids = append(ids, x)
+	}
+	table.SortIDs(ids)
+
+	for _, x := range ids {
+		if !fn(x, c.data[x]) {
+			return
+		}
+	}
+}
+
+func (c *C[T]) detach(x id.ID) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	delete(c.data, x)
+}
+
+// With wraps the input query filter to additionally require the entity to
+// have the component attached, e.g.
+//
+//	db.QueryAgents(aabb, With(health, func(a agent.RO) bool { return true }))
+func With[T any, E interface{ ID() id.ID }](c *C[T], filter func(e E) bool) func(e E) bool {
+	return func(e E) bool {
+		return c.Has(e.ID()) && filter(e)
+	}
+}
+
+// detach removes the input entity from all registered component stores.
+func (db *DB) detach(x id.ID) {
+	db.registryL.RLock()
+	defer db.registryL.RUnlock()
+
+	for _, c := range db.components {
+		c.detach(x)
+	}
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+)
+
+func TestComponent(t *testing.T) {
+	type health float64
+
+	db := New(DefaultO)
+	c := Register[health](db)
+
+	var agents []id.ID
+	for _, p := range []vector.V{{0, 0}, {3, 0}, {10, 0}} {
+		agents = append(agents, db.InsertAgent(roagent.O{
+			Position:       p,
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		}).ID())
+	}
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+	}).ID()
+
+	if err := c.TryAttach(100, 1); !errors.Is(err, dberrors.ErrNotFound) {
+		t.Errorf("TryAttach() = %v, want = %v", err, dberrors.ErrNotFound)
+	}
+
+	c.Attach(agents[0], 10)
+	c.Attach(agents[2], 30)
+	c.Attach(f, 100)
+
+	if got, ok := c.Get(agents[0]); !ok || got != 10 {
+		t.Errorf("Get() = %v, %v, want = %v, %v", got, ok, 10, true)
+	}
+	if want := []id.ID{agents[0], agents[2], f}; fmt.Sprint(c.IDs()) != fmt.Sprint(want) {
+		t.Errorf("IDs() = %v, want = %v", c.IDs(), want)
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		got := db.QueryAgentsInRadius(vector.V{0, 0}, 5, With(c, func(roagent.RO) bool { return true }))
+		if len(got) != 1 || got[0].ID() != agents[0] {
+			t.Errorf("QueryAgentsInRadius() = %v, want = [%v]", got, agents[0])
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		db.DeleteAgent(agents[0])
+		db.DeleteFeature(f)
+		if want := []id.ID{agents[2]}; fmt.Sprint(c.IDs()) != fmt.Sprint(want) {
+			t.Errorf("IDs() = %v, want = %v", c.IDs(), want)
+		}
+	})
+
+	t.Run("Detach", func(t *testing.T) {
+		c.Detach(agents[2])
+		if c.Has(agents[2]) {
+			t.Errorf("Has() = %v, want = %v", true, false)
+		}
+		if got := c.Len(); got != 0 {
+			t.Errorf("Len() = %v, want = %v", got, 0)
+		}
+	})
+}
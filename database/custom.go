@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+)
+
+// member is implemented by user-registered entity tables, and is used to check
+// if an ID is allocated to an entity in the table.
+type member interface {
+	has(x id.ID) bool
+}
+
+// Table is a user-registered entity table attached to a DB, e.g. to track
+// pickups or regions alongside the built-in agents, features and projectiles.
+//
+// Entities in the table share the ID space of the DB, and may therefore have
+// components attached (see Register).
+//
+// Table follows the same concurrency rules as the built-in entity kinds, i.e.
+// calls which mutate the BVH must be called serially, while read-only
+// operations may be called concurrently if the DB was created with
+// O.Concurrent set.
+type Table[E table.E] struct {
+	db   *DB
+	kind Kind
+
+	l rw
+	t *table.T[E]
+}
+
+// RegisterTable creates a new BVH-backed entity table for the DB, and
+// allocates a new entity kind for the table. The input name is used in error
+// messages.
+//
+// RegisterTable panics if all entity kinds have been allocated.
+func RegisterTable[E table.E](db *DB, name string) *Table[E] {
+	db.registryL.Lock()
+	defer db.registryL.Unlock()
+
+	k := KindUser + Kind(len(db.tables))
+	if k < KindUser {
+		panic(fmt.Sprintf("cannot register table %v: no entity kinds left", name))
+	}
+
+	t := &Table[E]{
+		db:   db,
+		kind: k,
+		l:    newRW(db.o.Concurrent),
+		t:    table.New[E](db.o.table(name)),
+	}
+	db.tables = append(db.tables, t)
+	return t
+}
+
+// Kind returns the entity kind allocated to the table. All IDs allocated by
+// the table encode this kind.
+func (t *Table[E]) Kind() Kind { return t.kind }
+
+// TryInsert allocates a new ID and inserts the entity returned by the input
+// constructor. The entity must report the allocated ID, or else an error
+// wrapping ErrInvalidOptions is returned.
+//
+// TryInsert mutates the BVH and must be called serially.
+func (t *Table[E]) TryInsert(fn func(x id.ID) E) (E, error) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	var zero E
+
+	x := t.db.id(t.kind)
+	e := fn(x)
+	if y := e.ID(); y != x {
+		return zero, fmt.Errorf("cannot insert %v %v: %w: entity reports ID %v", t.t.Name(), x, errors.ErrInvalidOptions, y)
+	}
+	if err := t.t.Insert(e); err != nil {
+		return zero, err
+	}
+	return e, nil
+}
+
+// Insert mutates the BVH and must be called serially.
+func (t *Table[E]) Insert(fn func(x id.ID) E) E {
+	e, err := t.TryInsert(fn)
+	if err != nil {
+		panic(err.Error())
+	}
+	return e
+}
+
+// TryDelete removes the entity from the table, along with all of its attached
+// components.
+//
+// TryDelete mutates the BVH and must be called serially.
+func (t *Table[E]) TryDelete(x id.ID) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if err := t.t.Delete(x); err != nil {
+		return err
+	}
+	t.db.detach(x)
+	return nil
+}
+
+// Delete mutates the BVH and must be called serially.
+func (t *Table[E]) Delete(x id.ID) { die(t.TryDelete(x)) }
+
+// TryUpdate refreshes the BVH after the AABB of the entity has changed.
+//
+// TryUpdate mutates the BVH and must be called serially.
+func (t *Table[E]) TryUpdate(x id.ID) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Update(x)
+}
+
+// Update mutates the BVH and must be called serially.
+func (t *Table[E]) Update(x id.ID) { die(t.TryUpdate(x)) }
+
+// Get is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (t *Table[E]) Get(x id.ID) (E, error) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Get(x)
+}
+
+// GetOrDie is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (t *Table[E]) GetOrDie(x id.ID) E {
+	e, err := t.Get(x)
+	if err != nil {
+		panic(err.Error())
+	}
+	return e
+}
+
+// Len is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (t *Table[E]) Len() int {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Len()
+}
+
+// List returns all entities in the table, and stops early if the input
+// context is cancelled.
+//
+// See ListAgentsContext for more information.
+func (t *Table[E]) List(ctx context.Context) <-chan E {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.List(ctx)
+}
+
+// ForEach calls fn on each entity in the table, and stops iterating early if fn
+// returns false.
+//
+// See ForEachAgent for more information.
+func (t *Table[E]) ForEach(fn func(e E) bool) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	t.t.ForEach(fn)
+}
+
+// Query is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (t *Table[E]) Query(q hyperrectangle.R, filter func(e E) bool) []E {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Query(q, filter)
+}
+
+// table returns the user-registered table of the input kind, or nil if no such
+// table exists.
+func (db *DB) table(k Kind) member {
+	db.registryL.RLock()
+	defer db.registryL.RUnlock()
+
+	if k < KindUser || int(k-KindUser) >= len(db.tables) {
+		return nil
+	}
+	return db.tables[k-KindUser]
+}
+
+func (t *Table[E]) has(x id.ID) bool {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	_, err := t.t.Get(x)
+	return err == nil
+}
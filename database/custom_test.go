@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	dberrors "github.com/downflux/go-database/errors"
+)
+
+type pickup struct {
+	id id.ID
+	p  vector.V
+}
+
+func (p *pickup) ID() id.ID { return p.id }
+func (p *pickup) AABB() hyperrectangle.R {
+	return *hyperrectangle.New(p.p, vector.Add(p.p, vector.V{1, 1}))
+}
+
+func TestRegisterTable(t *testing.T) {
+	db := New(DefaultO)
+	pickups := RegisterTable[*pickup](db, "pickup")
+	value := Register[int](db)
+
+	var xs []id.ID
+	for _, p := range []vector.V{{0, 0}, {5, 5}} {
+		p := p
+		xs = append(xs, pickups.Insert(func(x id.ID) *pickup {
+			return &pickup{id: x, p: p}
+		}).ID())
+	}
+
+	// Entities in user-registered tables share the DB ID space, and may
+	// therefore have components attached.
+	value.Attach(xs[0], 100)
+
+	got := pickups.Query(*hyperrectangle.New(vector.V{0, 0}, vector.V{2, 2}), func(*pickup) bool { return true })
+	if len(got) != 1 || got[0].ID() != xs[0] {
+		t.Errorf("Query() = %v, want = [%v]", got, xs[0])
+	}
+
+	pickups.Delete(xs[0])
+	if _, err := pickups.Get(xs[0]); !errors.Is(err, dberrors.ErrNotFound) {
+		t.Errorf("Get() = %v, want = %v", err, dberrors.ErrNotFound)
+	}
+	if value.Has(xs[0]) {
+		t.Errorf("Has() = %v, want = %v", true, false)
+	}
+	if got := pickups.Len(); got != 1 {
+		t.Errorf("Len() = %v, want = %v", got, 1)
+	}
+}
+
+func TestTableTryInsertMismatchedID(t *testing.T) {
+	db := New(DefaultO)
+	pickups := RegisterTable[*pickup](db, "pickup")
+
+	if _, err := pickups.TryInsert(func(x id.ID) *pickup {
+		return &pickup{id: x + 1}
+	}); !errors.Is(err, dberrors.ErrInvalidOptions) {
+		t.Errorf("TryInsert() = %v, want = %v", err, dberrors.ErrInvalidOptions)
+	}
+	if got := pickups.Len(); got != 0 {
+		t.Errorf("Len() = %v, want = %v", got, 0)
+	}
+}
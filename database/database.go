@@ -1,12 +1,18 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 
-	"github.com/downflux/go-bvh/bvh"
 	"github.com/downflux/go-bvh/container"
 	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/database/tree"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-database/flags"
 	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/team"
 	"github.com/downflux/go-database/internal/agent"
 	"github.com/downflux/go-database/internal/feature"
 	"github.com/downflux/go-database/internal/projectile"
@@ -16,8 +22,8 @@ import (
 
 	roagent "github.com/downflux/go-database/agent"
 	rofeature "github.com/downflux/go-database/feature"
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
 	roprojectile "github.com/downflux/go-database/projectile"
-	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
 )
 
 var (
@@ -31,124 +37,349 @@ var (
 )
 
 type RO interface {
+	GetAgent(x id.ID) (roagent.RO, error)
+	GetFeature(x id.ID) (rofeature.RO, error)
+	GetProjectile(x id.ID) (roprojectile.RO, error)
 	GetAgentOrDie(x id.ID) roagent.RO
 	GetFeatureOrDie(x id.ID) rofeature.RO
 	GetProjectileOrDie(x id.ID) roprojectile.RO
 	ListAgents() <-chan roagent.RO
 	ListFeatures() <-chan rofeature.RO
 	ListProjectiles() <-chan roprojectile.RO
+	ListAgentsContext(ctx context.Context) <-chan roagent.RO
+	ListFeaturesContext(ctx context.Context) <-chan rofeature.RO
+	ListProjectilesContext(ctx context.Context) <-chan roprojectile.RO
+	ForEachAgent(fn func(a roagent.RO) bool)
+	ForEachFeature(fn func(f rofeature.RO) bool)
+	ForEachProjectile(fn func(p roprojectile.RO) bool)
 	QueryAgents(q hyperrectangle.R, filter func(a roagent.RO) bool) []roagent.RO
 	QueryFeatures(q hyperrectangle.R, filter func(a rofeature.RO) bool) []rofeature.RO
+	QueryProjectiles(q hyperrectangle.R, filter func(a roprojectile.RO) bool) []roprojectile.RO
+	QueryAgentsInRadius(p vector.V, r float64, filter func(a roagent.RO) bool) []roagent.RO
+	QueryFeaturesInRadius(p vector.V, r float64, filter func(a rofeature.RO) bool) []rofeature.RO
 }
 
 type O struct {
 	LeafSize  int
 	Tolerance float64
+
+	// Concurrent guards the DB with internal locks. By default, the DB
+	// relies on the caller to respect the concurrency contract documented
+	// on each method, e.g. that BVH mutations are called serially. Setting
+	// this flag enforces the contract instead, at the cost of lock
+	// overhead on every call --
+	//
+	//  1. calls which mutate an entity map or BVH (e.g. InsertAgent,
+	//     SetAgentPosition) block all other calls on the same entity type,
+	//  1. read-only calls (e.g. GetAgent, QueryAgents) may run concurrently
+	//     with one another, and
+	//  1. calls which only mutate a single entity (e.g. SetAgentVelocity)
+	//     may run concurrently with read-only calls and with calls on
+	//     other entities, and are serialized with calls on the same
+	//     entity.
+	//
+	// Note that the read-only entity references returned by the DB are
+	// not themselves guarded; callers must not read the fields of an
+	// entity while concurrently mutating that same entity.
+	Concurrent bool
+
+	// Ordered sorts the output of all listing and query operations by
+	// entity ID. As IDs are allocated monotonically, this is also the
+	// insertion order of the entities. This is necessary for e.g. lockstep
+	// simulations, where all peers must process entities in the same
+	// order.
+	//
+	// Listing operations iterate over a pre-sorted index, and query
+	// operations sort only the (small) set of BVH candidates.
+	Ordered bool
+
+	// DoubleBuffered separates the state which is read from the state
+	// which is written. All Get*, List*, ForEach* and Query* calls read
+	// from a frozen front buffer, while all inserts, deletes and setters
+	// write to the back buffer. Mutations are only visible to readers
+	// after the next call to DB.Swap. This allows e.g. all systems in a
+	// game loop to read the state of tick N and write the state of tick N
+	// + 1, independent of the order in which the systems are run.
+	//
+	// The front buffer is a Snapshot of the DB, and entities are copied
+	// into the front buffer at most once per tick, on first mutation.
+	// The spatial helpers, i.e. Nearest*, Raycast*, *Pairs and
+	// SweepProjectile, also read the front buffer.
+	//
+	// Component stores and tables added with RegisterTable are not
+	// buffered, and their mutations are visible immediately.
+	DoubleBuffered bool
 }
 
 type DB struct {
-	agents      map[id.ID]*agent.A
-	features    map[id.ID]*feature.F
-	projectiles map[id.ID]*projectile.P
+	agents      *table.T[*agent.A]
+	features    *table.T[*feature.F]
+	projectiles *table.T[*projectile.P]
+
+	// agentsL guards the agents table, including its BVH.
+	agentsL      rw
+	featuresL    rw
+	projectilesL rw
+
+	// guards serializes non-BVH mutations on individual entities.
+	guards guards
 
-	agentsBVH   container.C
-	featuresBVH container.C
+	// o is the set of options the DB was created with, and is used to set
+	// up user-registered tables and component stores.
+	o O
 
+	// registryL guards the lists of user-registered component stores and
+	// entity tables. Component stores are notified on entity deletion.
+	registryL  rw
+	components []detacher
+	tables     []member
+
+	// snapshotsL guards the list of open snapshots, which are notified
+	// before any entity is mutated.
+	snapshotsL rw
+	snapshots  []*Snapshot
+
+	// frontL guards the front buffer of a double-buffered DB, and must
+	// be acquired before any entity lock.
+	frontL rw
+	front  *Snapshot
+
+	// counter must be accessed atomically, as inserts of different entity
+	// types may run concurrently. The counter is shared by all kinds, and
+	// the kind is additionally encoded in the high bits of each ID.
 	counter uint64
 }
 
 func New(o O) *DB {
-	return &DB{
-		agents:      make(map[id.ID]*agent.A, 1024),
-		features:    make(map[id.ID]*feature.F, 1024),
-		projectiles: make(map[id.ID]*projectile.P, 1024),
-		agentsBVH: bvh.New(bvh.O{
-			K:         2,
-			LeafSize:  o.LeafSize,
-			Tolerance: o.Tolerance,
-		}),
-		featuresBVH: bvh.New(bvh.O{
-			K:         2,
-			LeafSize:  o.LeafSize,
-			Tolerance: o.Tolerance,
-		}),
+	db := &DB{
+		agents:       table.New[*agent.A](o.table("agent")),
+		features:     table.New[*feature.F](o.table("feature")),
+		projectiles:  table.New[*projectile.P](o.table("projectile")),
+		agentsL:      newRW(o.Concurrent),
+		featuresL:    newRW(o.Concurrent),
+		projectilesL: newRW(o.Concurrent),
+		guards:       newGuards(o.Concurrent),
+		o:            o,
+		registryL:    newRW(o.Concurrent),
+		snapshotsL:   newRW(o.Concurrent),
+		frontL:       newRW(o.Concurrent),
+	}
+	if o.DoubleBuffered {
+		db.front = db.Snapshot()
+	}
+	return db
+}
+
+// table returns the options for a BVH-backed table of the input entity kind.
+func (o O) table(name string) table.O {
+	return table.O{
+		Name:    name,
+		Index:   o.bvh(),
+		Ordered: o.Ordered,
+	}
+}
+
+// bvh returns a new spatial index over entity AABBs. The index exposes its
+// node hierarchy, which is necessary for e.g. best-first nearest neighbor
+// searches.
+func (o O) bvh() container.C {
+	t, err := tree.New(tree.O{
+		LeafSize:  o.LeafSize,
+		Tolerance: o.Tolerance,
+	})
+	die(err)
+	return t
+}
+
+// GetAgent is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (db *DB) GetAgent(x id.ID) (roagent.RO, error) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.GetAgent(x)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetFeature is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) GetFeature(x id.ID) (rofeature.RO, error) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.GetFeature(x)
+	}
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	f, err := db.features.Get(x)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetProjectile is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) GetProjectile(x id.ID) (roprojectile.RO, error) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.GetProjectile(x)
+	}
+
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return nil, err
 	}
+	return p, nil
 }
 
 // GetAgentOrDie is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) GetAgentOrDie(x id.ID) roagent.RO {
-	if a, ok := db.agents[x]; !ok {
-		panic(fmt.Sprintf("cannot find agent %v", x))
-	} else {
-		return a
+	a, err := db.GetAgent(x)
+	if err != nil {
+		panic(err.Error())
 	}
+	return a
 }
 
 // GetFeatureOrDie is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) GetFeatureOrDie(x id.ID) rofeature.RO {
-	if a, ok := db.features[x]; !ok {
-		panic(fmt.Sprintf("cannot find feature %v", x))
-	} else {
-		return a
+	f, err := db.GetFeature(x)
+	if err != nil {
+		panic(err.Error())
 	}
+	return f
 }
 
 // GetProjectileOrDie is a read-only operation and may be called concurrently
 // with other read-only operations.
 func (db *DB) GetProjectileOrDie(x id.ID) roprojectile.RO {
-	if a, ok := db.projectiles[x]; !ok {
-		panic(fmt.Sprintf("cannot find projectile %v", x))
-	} else {
-		return a
+	p, err := db.GetProjectile(x)
+	if err != nil {
+		panic(err.Error())
 	}
+	return p
 }
 
-// InsertAgent mutates the DB and must be called serially.
-func (db *DB) InsertAgent(o roagent.O) roagent.RO {
-	x := id.ID(db.counter)
-	db.counter += 1
+// TryInsertAgent mutates the DB and must be called serially.
+//
+// If an error is returned, the DB is left unchanged, except that the ID
+// allocated to the new entity is consumed and will not be reused.
+func (db *DB) TryInsertAgent(o roagent.O) (roagent.RO, error) {
+	if !agent.Validate(agent.O(o)) {
+		return nil, fmt.Errorf("cannot insert agent: %w", errors.ErrInvalidOptions)
+	}
+
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	x := db.id(KindAgent)
 
 	a := agent.New(agent.O(o))
 	a.SetID(x)
 
-	db.agents[x] = a
-	if err := db.agentsBVH.Insert(x, hnd.R(a.AABB())); err != nil {
-		panic(fmt.Sprintf("cannot insert agent: %v", err))
+	if err := db.agents.Insert(a); err != nil {
+		return nil, err
 	}
 
-	return a
+	return a, nil
 }
 
-// InsertFeature mutates the DB and must be called serially.
-func (db *DB) InsertFeature(o rofeature.O) rofeature.RO {
-	x := id.ID(db.counter)
-	db.counter += 1
+// TryInsertFeature mutates the DB and must be called serially.
+//
+// If an error is returned, the DB is left unchanged, except that the ID
+// allocated to the new entity is consumed and will not be reused.
+func (db *DB) TryInsertFeature(o rofeature.O) (rofeature.RO, error) {
+	if !feature.Validate(feature.O(o)) {
+		return nil, fmt.Errorf("cannot insert feature: %w", errors.ErrInvalidOptions)
+	}
 
-	a := feature.New(feature.O(o))
-	a.SetID(x)
+	db.featuresL.Lock()
+	defer db.featuresL.Unlock()
+
+	x := db.id(KindFeature)
 
-	db.features[x] = a
-	if err := db.featuresBVH.Insert(x, hnd.R(a.AABB())); err != nil {
-		panic(fmt.Sprintf("cannot insert feature: %v", err))
+	f := feature.New(feature.O(o))
+	f.SetID(x)
+
+	if err := db.features.Insert(f); err != nil {
+		return nil, err
 	}
 
-	return a
+	return f, nil
 }
 
-// InsertProjectile mutates the DB and must be called serially.
-func (db *DB) InsertProjectile(o roprojectile.O) roprojectile.RO {
-	x := id.ID(db.counter)
-	db.counter += 1
+// TryInsertProjectile mutates the DB and must be called serially.
+//
+// If an error is returned, the DB is left unchanged, except that the ID
+// allocated to the new entity is consumed and will not be reused.
+func (db *DB) TryInsertProjectile(o roprojectile.O) (roprojectile.RO, error) {
+	if !projectile.Validate(projectile.O(o)) {
+		return nil, fmt.Errorf("cannot insert projectile: %w", errors.ErrInvalidOptions)
+	}
 
-	a := projectile.New(projectile.O(o))
-	a.SetID(x)
+	db.projectilesL.Lock()
+	defer db.projectilesL.Unlock()
+
+	x := db.id(KindProjectile)
+
+	p := projectile.New(projectile.O(o))
+	p.SetID(x)
+
+	if err := db.projectiles.Insert(p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
 
-	db.projectiles[x] = a
+// InsertAgent mutates the DB and must be called serially.
+func (db *DB) InsertAgent(o roagent.O) roagent.RO {
+	a, err := db.TryInsertAgent(o)
+	if err != nil {
+		panic(err.Error())
+	}
 	return a
 }
 
+// InsertFeature mutates the DB and must be called serially.
+func (db *DB) InsertFeature(o rofeature.O) rofeature.RO {
+	f, err := db.TryInsertFeature(o)
+	if err != nil {
+		panic(err.Error())
+	}
+	return f
+}
+
+// InsertProjectile mutates the DB and must be called serially.
+func (db *DB) InsertProjectile(o roprojectile.O) roprojectile.RO {
+	p, err := db.TryInsertProjectile(o)
+	if err != nil {
+		panic(err.Error())
+	}
+	return p
+}
+
 // ListAgents returns all agents in the DB. There are serveral use-cases for
 // this method which changes the invocation pattern.
 //
@@ -156,31 +387,19 @@ func (db *DB) InsertProjectile(o roprojectile.O) roprojectile.RO {
 //  1. Agent-specific mutations should first iterate over the returned values
 //     and create a proposal batch of changes. If these changes do not modify
 //     the BVH, they may be run in parallel. Changes to the BVH (e.g.
-//     SetAgentPosition) must be done serially.
-func (db *DB) ListAgents() <-chan roagent.RO {
-	ch := make(chan roagent.RO, 256)
-	go func(ch chan<- roagent.RO) {
-		defer close(ch)
-		for _, a := range db.agents {
-			ch <- a
-		}
-	}(ch)
-	return ch
-}
+//     SetAgentPosition) must be done serially. Alternatively, the batch
+//     may be buffered in a Tx and applied atomically.
+//
+// The caller must drain the returned channel. Callers which may stop early
+// should use ListAgentsContext or ForEachAgent instead.
+func (db *DB) ListAgents() <-chan roagent.RO { return db.ListAgentsContext(context.Background()) }
 
 // ListFeatures returns all features in the DB. There are serveral use-cases for
 // this method which changes the invocation pattern.
 //
 // See ListAgents for more information.
 func (db *DB) ListFeatures() <-chan rofeature.RO {
-	ch := make(chan rofeature.RO, 256)
-	go func(ch chan<- rofeature.RO) {
-		defer close(ch)
-		for _, a := range db.features {
-			ch <- a
-		}
-	}(ch)
-	return ch
+	return db.ListFeaturesContext(context.Background())
 }
 
 // ListProjectiles returns all projectiles in the DB. There are serveral
@@ -188,57 +407,233 @@ func (db *DB) ListFeatures() <-chan rofeature.RO {
 //
 // See ListAgents for more information.
 func (db *DB) ListProjectiles() <-chan roprojectile.RO {
-	ch := make(chan roprojectile.RO, 256)
-	go func(ch chan<- roprojectile.RO) {
-		defer close(ch)
-		for _, a := range db.projectiles {
-			ch <- a
-		}
-	}(ch)
-	return ch
+	return db.ListProjectilesContext(context.Background())
 }
 
-// DeleteAgent mutates the DB and must be called serially.
-func (db *DB) DeleteAgent(x id.ID) {
-	if _, ok := db.agents[x]; !ok {
-		panic(fmt.Sprintf("cannot find agent %v", x))
+// ListAgentsContext returns all agents in the DB. The returned channel is
+// closed after all agents have been sent, or after the input context is
+// cancelled, whichever comes first. The caller may therefore stop reading
+// early without leaking the underlying goroutine by cancelling the context.
+//
+// All agents are copied into a list up front, and the agent read lock is
+// released before the first agent is sent. The copy costs O(n) time and memory
+// per call, but allows the caller to mutate the DB, e.g. via
+// SetAgentPosition, while draining the channel. Streaming under the read lock
+// would instead deadlock such callers. Callers which do not need a channel
+// should use ForEachAgent, which does not allocate.
+//
+// See ListAgents for more information.
+func (db *DB) ListAgentsContext(ctx context.Context) <-chan roagent.RO {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.ListAgentsContext(ctx)
 	}
 
-	delete(db.agents, x)
-	if err := db.agentsBVH.Remove(x); err != nil {
-		panic(fmt.Sprintf("cannot delete agent: %v", err))
+	db.agentsL.RLock()
+	agents := make([]roagent.RO, 0, db.agents.Len())
+	db.forEachAgent(func(a roagent.RO) bool {
+		agents = append(agents, a)
+		return true
+	})
+	db.agentsL.RUnlock()
+
+	return table.Stream(ctx, agents)
+}
+
+// ListFeaturesContext returns all features in the DB, and stops early if the input
+// context is cancelled.
+//
+// See ListAgentsContext for more information.
+func (db *DB) ListFeaturesContext(ctx context.Context) <-chan rofeature.RO {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.ListFeaturesContext(ctx)
 	}
+
+	db.featuresL.RLock()
+	features := make([]rofeature.RO, 0, db.features.Len())
+	db.forEachFeature(func(f rofeature.RO) bool {
+		features = append(features, f)
+		return true
+	})
+	db.featuresL.RUnlock()
+
+	return table.Stream(ctx, features)
 }
 
-// DeleteFeature mutates the DB and must be called serially.
-func (db *DB) DeleteFeature(x id.ID) {
-	if _, ok := db.features[x]; !ok {
-		panic(fmt.Sprintf("cannot find feature %v", x))
+// ListProjectilesContext returns all projectiles in the DB, and stops early if the input
+// context is cancelled.
+//
+// See ListAgentsContext for more information.
+func (db *DB) ListProjectilesContext(ctx context.Context) <-chan roprojectile.RO {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.ListProjectilesContext(ctx)
 	}
 
-	delete(db.features, x)
-	if err := db.featuresBVH.Remove(x); err != nil {
-		panic(fmt.Sprintf("cannot delete feature: %v", err))
+	db.projectilesL.RLock()
+	projectiles := make([]roprojectile.RO, 0, db.projectiles.Len())
+	db.forEachProjectile(func(p roprojectile.RO) bool {
+		projectiles = append(projectiles, p)
+		return true
+	})
+	db.projectilesL.RUnlock()
+
+	return table.Stream(ctx, projectiles)
+}
+
+// ForEachAgent calls fn on each agent in the DB, and stops iterating early if
+// fn returns false. ForEachAgent does not allocate, and is the preferred method
+// of iteration in hot loops.
+//
+// If the DB is double-buffered, the first iteration after each Swap copies all
+// agents which have not been mutated since into the front buffer, and
+// allocates the list of copies. Later iterations until the next Swap reuse the
+// list and do not allocate.
+//
+// ForEachAgent is a read-only operation and may be called concurrently with
+// other read-only operations. The input function must not mutate the agents
+// BVH, e.g. via SetAgentPosition.
+func (db *DB) ForEachAgent(fn func(a roagent.RO) bool) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		db.front.ForEachAgent(fn)
+		return
 	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	db.forEachAgent(fn)
 }
 
-// DeleteProjectile mutates the DB and must be called serially.
-func (db *DB) DeleteProjectile(x id.ID) {
-	if _, ok := db.projectiles[x]; !ok {
-		panic(fmt.Sprintf("cannot find projectile %v", x))
+// ForEachFeature calls fn on each feature in the DB, and stops iterating
+// early if fn returns false.
+//
+// See ForEachAgent for more information.
+func (db *DB) ForEachFeature(fn func(f rofeature.RO) bool) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		db.front.ForEachFeature(fn)
+		return
+	}
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	db.forEachFeature(fn)
+}
+
+// ForEachProjectile calls fn on each projectile in the DB, and stops iterating
+// early if fn returns false.
+//
+// See ForEachAgent for more information.
+func (db *DB) ForEachProjectile(fn func(p roprojectile.RO) bool) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		db.front.ForEachProjectile(fn)
+		return
+	}
+
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	db.forEachProjectile(fn)
+}
+
+// TryDeleteAgent mutates the DB and must be called serially.
+func (db *DB) TryDeleteAgent(x id.ID) error {
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+	db.preserveAgent(a)
+
+	if err := db.agents.Delete(x); err != nil {
+		return err
+	}
+	db.detach(x)
+	return nil
+}
+
+// TryDeleteFeature mutates the DB and must be called serially.
+func (db *DB) TryDeleteFeature(x id.ID) error {
+	db.featuresL.Lock()
+	defer db.featuresL.Unlock()
+
+	f, err := db.features.Get(x)
+	if err != nil {
+		return err
 	}
+	db.preserveFeature(f)
 
-	delete(db.projectiles, x)
+	if err := db.features.Delete(x); err != nil {
+		return err
+	}
+	db.detach(x)
+	return nil
 }
 
+// TryDeleteProjectile mutates the DB and must be called serially.
+func (db *DB) TryDeleteProjectile(x id.ID) error {
+	db.projectilesL.Lock()
+	defer db.projectilesL.Unlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return err
+	}
+	db.preserveProjectile(p)
+
+	if err := db.projectiles.Delete(x); err != nil {
+		return err
+	}
+	db.detach(x)
+	return nil
+}
+
+// DeleteAgent mutates the DB and must be called serially.
+func (db *DB) DeleteAgent(x id.ID) { die(db.TryDeleteAgent(x)) }
+
+// DeleteFeature mutates the DB and must be called serially.
+func (db *DB) DeleteFeature(x id.ID) { die(db.TryDeleteFeature(x)) }
+
+// DeleteProjectile mutates the DB and must be called serially.
+func (db *DB) DeleteProjectile(x id.ID) { die(db.TryDeleteProjectile(x)) }
+
 // QueryAgents is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) QueryAgents(q hyperrectangle.R, filter func(a roagent.RO) bool) []roagent.RO {
-	candidates := db.agentsBVH.BroadPhase(hnd.R(q))
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.QueryAgents(q, filter)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	candidates := db.agents.BroadPhase(q)
 
 	results := make([]roagent.RO, 0, len(candidates))
 	for _, x := range candidates {
-		a := db.agents[x]
+		a := db.agents.At(x)
 		if filter(a) {
 			results = append(results, a)
 		}
@@ -249,11 +644,21 @@ func (db *DB) QueryAgents(q hyperrectangle.R, filter func(a roagent.RO) bool) []
 // QueryFeatures is a read-only operation and may be called concurrently with
 // other read-only operations.
 func (db *DB) QueryFeatures(q hyperrectangle.R, filter func(a rofeature.RO) bool) []rofeature.RO {
-	candidates := db.featuresBVH.BroadPhase(hnd.R(q))
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.QueryFeatures(q, filter)
+	}
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	candidates := db.features.BroadPhase(q)
 
 	results := make([]rofeature.RO, 0, len(candidates))
 	for _, x := range candidates {
-		a := db.features[x]
+		a := db.features.At(x)
 		if filter(a) {
 			results = append(results, a)
 		}
@@ -261,70 +666,444 @@ func (db *DB) QueryFeatures(q hyperrectangle.R, filter func(a rofeature.RO) bool
 	return results
 }
 
-// SetAgentPosition mutates the BVH and must be called serially.
-func (db *DB) SetAgentPosition(x id.ID, v vector.V) {
-	a := db.GetAgentOrDie(x)
+// QueryProjectiles is a read-only operation and may be called concurrently
+// with other read-only operations.
+func (db *DB) QueryProjectiles(q hyperrectangle.R, filter func(a roprojectile.RO) bool) []roprojectile.RO {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.QueryProjectiles(q, filter)
+	}
+
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	candidates := db.projectiles.BroadPhase(q)
+
+	results := make([]roprojectile.RO, 0, len(candidates))
+	for _, x := range candidates {
+		a := db.projectiles.At(x)
+		if filter(a) {
+			results = append(results, a)
+		}
+	}
+	return results
+}
+
+// QueryAgentsInRadius returns all agents whose circle overlaps the circle of
+// radius r centered at p.
+//
+// QueryAgentsInRadius is a read-only operation and may be called concurrently
+// with other read-only operations.
+func (db *DB) QueryAgentsInRadius(p vector.V, r float64, filter func(a roagent.RO) bool) []roagent.RO {
+	return db.QueryAgents(bound(p, r), func(a roagent.RO) bool {
+		d := r + a.Radius()
+		if vector.SquaredMagnitude(vector.Sub(a.Position(), p)) > d*d {
+			return false
+		}
+		return filter(a)
+	})
+}
+
+// QueryFeaturesInRadius returns all features whose AABB overlaps the circle of
+// radius r centered at p.
+//
+// QueryFeaturesInRadius is a read-only operation and may be called
+// concurrently with other read-only operations.
+func (db *DB) QueryFeaturesInRadius(p vector.V, r float64, filter func(a rofeature.RO) bool) []rofeature.RO {
+	return db.QueryFeatures(bound(p, r), func(f rofeature.RO) bool {
+		if !dhr.IntersectCircle(f.AABB(), p, r) {
+			return false
+		}
+		return filter(f)
+	})
+}
+
+// TrySetAgentPosition mutates the BVH and must be called serially.
+func (db *DB) TrySetAgentPosition(x id.ID, v vector.V) error {
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.preserveAgent(a)
+
+	u := clone(a.Position())
+	a.SetPosition(v)
+	if err := db.agents.Update(x); err != nil {
+		a.SetPosition(u)
+		return err
+	}
+	return nil
+}
+
+// TrySetAgentTargetPosition does not mutate the BVH and may be called
+// concurrently with calls on other agents.
+func (db *DB) TrySetAgentTargetPosition(x id.ID, v vector.V) error {
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveAgent(a)
+	a.SetTargetPosition(v)
+	return nil
+}
+
+// TrySetAgentVelocity does not mutate the BVH and may be called concurrently
+// with calls on other agents.
+func (db *DB) TrySetAgentVelocity(x id.ID, v vector.V) error {
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveAgent(a)
+	a.SetVelocity(v)
+	return nil
+}
+
+// TrySetAgentTargetVelocity does not mutate the BVH and may be called
+// concurrently with calls on other agents.
+func (db *DB) TrySetAgentTargetVelocity(x id.ID, v vector.V) error {
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveAgent(a)
+	a.SetTargetVelocity(v)
+	return nil
+}
+
+// TrySetAgentHeading does not mutate the BVH and may be called concurrently
+// with calls on other agents.
+func (db *DB) TrySetAgentHeading(x id.ID, v polar.V) error {
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveAgent(a)
+	a.SetHeading(v)
+	return nil
+}
+
+// TrySetAgentMoveMode does not mutate the BVH and may be called concurrently
+// with calls on other agents.
+func (db *DB) TrySetAgentMoveMode(x id.ID, f move.F) error {
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+
+	if !move.Validate(f) {
+		return fmt.Errorf("cannot set move mode %v for agent %v: %w", f, x, errors.ErrInvalidOptions)
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveAgent(a)
+	a.SetMoveMode(f)
+	return nil
+}
+
+// TrySetFeatureAABB mutates the BVH and must be called serially.
+func (db *DB) TrySetFeatureAABB(x id.ID, aabb hyperrectangle.R) error {
+	db.featuresL.Lock()
+	defer db.featuresL.Unlock()
+
+	f, err := db.features.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.preserveFeature(f)
 
-	a.(*agent.A).SetPosition(v)
-	db.agentsBVH.Update(x, hnd.R(a.AABB()))
+	r := f.AABB()
+	r = *hyperrectangle.New(clone(r.Min()), clone(r.Max()))
+	f.SetAABB(aabb)
+	if err := db.features.Update(x); err != nil {
+		f.SetAABB(r)
+		return err
+	}
+	return nil
+}
+
+// TrySetFeatureFlags does not mutate the BVH and may be called concurrently
+// with calls on other features.
+func (db *DB) TrySetFeatureFlags(x id.ID, g flags.F) error {
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	f, err := db.features.Get(x)
+	if err != nil {
+		return err
+	}
+	if !flags.Validate(g) {
+		return fmt.Errorf("cannot set flags %v for feature %v: %w", g, x, errors.ErrInvalidOptions)
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveFeature(f)
+	f.SetFlags(g)
+	return nil
+}
+
+// TrySetFeatureTeam does not mutate the BVH and may be called concurrently
+// with calls on other features.
+func (db *DB) TrySetFeatureTeam(x id.ID, t team.F) error {
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	f, err := db.features.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveFeature(f)
+	f.SetTeam(t)
+	return nil
+}
+
+// TrySetProjectilePosition mutates the BVH and must be called serially.
+func (db *DB) TrySetProjectilePosition(x id.ID, v vector.V) error {
+	db.projectilesL.Lock()
+	defer db.projectilesL.Unlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.preserveProjectile(p)
+
+	u := clone(p.Position())
+	p.SetPosition(v)
+	if err := db.projectiles.Update(x); err != nil {
+		p.SetPosition(u)
+		return err
+	}
+	return nil
+}
+
+// TrySetProjectileTargetPosition does not mutate the BVH and may be called
+// concurrently with calls on other projectiles.
+func (db *DB) TrySetProjectileTargetPosition(x id.ID, v vector.V) error {
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveProjectile(p)
+	p.SetTargetPosition(v)
+	return nil
+}
+
+// TrySetProjectileVelocity does not mutate the BVH and may be called
+// concurrently with calls on other projectiles.
+func (db *DB) TrySetProjectileVelocity(x id.ID, v vector.V) error {
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveProjectile(p)
+	p.SetVelocity(v)
+	return nil
+}
+
+// TrySetProjectileTargetVelocity does not mutate the BVH and may be called
+// concurrently with calls on other projectiles.
+func (db *DB) TrySetProjectileTargetVelocity(x id.ID, v vector.V) error {
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveProjectile(p)
+	p.SetTargetVelocity(v)
+	return nil
+}
+
+// TrySetProjectileHeading does not mutate the BVH and may be called
+// concurrently with calls on other projectiles.
+func (db *DB) TrySetProjectileHeading(x id.ID, v polar.V) error {
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return err
+	}
+
+	db.guards.Lock(x)
+	defer db.guards.Unlock(x)
+
+	db.preserveProjectile(p)
+	p.SetHeading(v)
+	return nil
 }
 
+// SetAgentPosition mutates the BVH and must be called serially.
+func (db *DB) SetAgentPosition(x id.ID, v vector.V) { die(db.TrySetAgentPosition(x, v)) }
+
 // SetAgentTargetPosition does not mutate the BVH and may be called concurrently
 // with calls on other agents.
 func (db *DB) SetAgentTargetPosition(x id.ID, v vector.V) {
-	db.GetAgentOrDie(x).(*agent.A).SetTargetPosition(v)
+	die(db.TrySetAgentTargetPosition(x, v))
 }
 
 // SetAgentVelocity does not mutate the BVH and may be called concurrently with
 // calls on other agents.
-func (db *DB) SetAgentVelocity(x id.ID, v vector.V) {
-	db.GetAgentOrDie(x).(*agent.A).SetVelocity(v)
-}
+func (db *DB) SetAgentVelocity(x id.ID, v vector.V) { die(db.TrySetAgentVelocity(x, v)) }
 
 // SetAgentTargetVelocity does not mutate the BVH and may be called concurrently
 // with calls on other agents.
 func (db *DB) SetAgentTargetVelocity(x id.ID, v vector.V) {
-	db.GetAgentOrDie(x).(*agent.A).SetTargetVelocity(v)
+	die(db.TrySetAgentTargetVelocity(x, v))
 }
 
 // SetAgentHeading does not mutate the BVH and may be called concurrently with
 // calls on other agents.
-func (db *DB) SetAgentHeading(x id.ID, v polar.V) {
-	db.GetAgentOrDie(x).(*agent.A).SetHeading(v)
-}
+func (db *DB) SetAgentHeading(x id.ID, v polar.V) { die(db.TrySetAgentHeading(x, v)) }
 
 // SetAgentMoveMode does not mutate the BVH and may be called concurrently with
 // calls on other agents.
-func (db *DB) SetAgentMoveMode(x id.ID, f move.F) {
-	db.GetAgentOrDie(x).(*agent.A).SetMoveMode(f)
+func (db *DB) SetAgentMoveMode(x id.ID, f move.F) { die(db.TrySetAgentMoveMode(x, f)) }
+
+// SetFeatureAABB mutates the BVH and must be called serially. The feature
+// retains its ID.
+func (db *DB) SetFeatureAABB(x id.ID, aabb hyperrectangle.R) {
+	die(db.TrySetFeatureAABB(x, aabb))
 }
 
-// SetProjectilePosition does not mutate the BVH and may be called concurrently
-// with calls on other projectiles.
+// SetFeatureFlags does not mutate the BVH and may be called concurrently with
+// calls on other features.
+func (db *DB) SetFeatureFlags(x id.ID, f flags.F) { die(db.TrySetFeatureFlags(x, f)) }
+
+// SetFeatureTeam does not mutate the BVH and may be called concurrently with
+// calls on other features.
+func (db *DB) SetFeatureTeam(x id.ID, t team.F) { die(db.TrySetFeatureTeam(x, t)) }
+
+// SetProjectilePosition mutates the BVH and must be called serially.
 func (db *DB) SetProjectilePosition(x id.ID, v vector.V) {
-	db.GetProjectileOrDie(x).(*projectile.P).SetPosition(v)
+	die(db.TrySetProjectilePosition(x, v))
 }
 
 // SetProjectileTargetPosition does not mutate the BVH and may be called
 // concurrently with calls on other projectiles.
 func (db *DB) SetProjectileTargetPosition(x id.ID, v vector.V) {
-	db.GetProjectileOrDie(x).(*projectile.P).SetTargetPosition(v)
+	die(db.TrySetProjectileTargetPosition(x, v))
 }
 
 // SetProjectileVelocity does not mutate the BVH and may be called concurrently
 // with calls on other projectiles.
 func (db *DB) SetProjectileVelocity(x id.ID, v vector.V) {
-	db.GetProjectileOrDie(x).(*projectile.P).SetVelocity(v)
+	die(db.TrySetProjectileVelocity(x, v))
 }
 
 // SetProjectileTargetVelocity does not mutate the BVH and may be called
 // concurrently with calls on other projectiles.
 func (db *DB) SetProjectileTargetVelocity(x id.ID, v vector.V) {
-	db.GetProjectileOrDie(x).(*projectile.P).SetTargetVelocity(v)
+	die(db.TrySetProjectileTargetVelocity(x, v))
 }
 
 // SetProjectileHeading does not mutate the BVH and may be called concurrently
 // with calls on other projectiles.
 func (db *DB) SetProjectileHeading(x id.ID, v polar.V) {
-	db.GetProjectileOrDie(x).(*projectile.P).SetHeading(v)
+	die(db.TrySetProjectileHeading(x, v))
+}
+
+// id allocates a new entity ID of the input kind.
+func (db *DB) id(k Kind) id.ID {
+	return id.ID(k)<<kindShift | id.ID(atomic.AddUint64(&db.counter, 1)-1)
+}
+
+// count returns the number of IDs allocated so far.
+func (db *DB) count() uint64 { return atomic.LoadUint64(&db.counter) }
+
+// count returns the counter bits of the input ID, i.e. the ID without its
+// kind.
+func count(x id.ID) uint64 { return uint64(x) & (1<<kindShift - 1) }
+
+// bound returns the AABB of the circle of radius r centered at p.
+func bound(p vector.V, r float64) hyperrectangle.R {
+	return *hyperrectangle.New(
+		vector.V{p.X() - r, p.Y() - r},
+		vector.V{p.X() + r, p.Y() + r},
+	)
+}
+
+func die(err error) {
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (db *DB) forEachAgent(fn func(a roagent.RO) bool) {
+	db.agents.ForEach(func(a *agent.A) bool { return fn(a) })
+}
+
+func (db *DB) forEachFeature(fn func(f rofeature.RO) bool) {
+	db.features.ForEach(func(f *feature.F) bool { return fn(f) })
+}
+
+func (db *DB) forEachProjectile(fn func(p roprojectile.RO) bool) {
+	db.projectiles.ForEach(func(p *projectile.P) bool { return fn(p) })
 }
@@ -1,10 +1,342 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-database/database/cache"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/internal/agent"
+	"github.com/downflux/go-database/internal/feature"
+	"github.com/downflux/go-database/internal/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
 )
 
 var (
 	_ RO = &DB{}
 	_ RO = &cache.DB{}
 )
+
+func TestErrors(t *testing.T) {
+	type config struct {
+		name string
+		f    func(db *DB) error
+		want error
+	}
+
+	configs := []config{
+		{
+			name: "GetAgent/NotFound",
+			f: func(db *DB) error {
+				_, err := db.GetAgent(100)
+				return err
+			},
+			want: dberrors.ErrNotFound,
+		},
+		{
+			name: "TryDeleteFeature/NotFound",
+			f:    func(db *DB) error { return db.TryDeleteFeature(100) },
+			want: dberrors.ErrNotFound,
+		},
+		{
+			name: "TrySetAgentPosition/NotFound",
+			f:    func(db *DB) error { return db.TrySetAgentPosition(100, vector.V{0, 0}) },
+			want: dberrors.ErrNotFound,
+		},
+		{
+			name: "TryInsertAgent/InvalidOptions",
+			f: func(db *DB) error {
+				_, err := db.TryInsertAgent(roagent.O{})
+				return err
+			},
+			want: dberrors.ErrInvalidOptions,
+		},
+		{
+			name: "TryInsertAgent/Valid",
+			f: func(db *DB) error {
+				_, err := db.TryInsertAgent(roagent.O{
+					Position:       vector.V{0, 0},
+					TargetPosition: vector.V{0, 0},
+					Velocity:       vector.V{0, 0},
+					TargetVelocity: vector.V{0, 0},
+					Heading:        polar.V{1, 0},
+					Radius:         1,
+					Mass:           1,
+					Size:           size.FSmall,
+				})
+				return err
+			},
+			want: nil,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.f(New(DefaultO)); !errors.Is(got, c.want) {
+				t.Errorf("f() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTryDeleteAgent(t *testing.T) {
+	db := New(DefaultO)
+	x := db.InsertAgent(roagent.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		Size:           size.FSmall,
+	}).ID()
+
+	if err := db.TryDeleteAgent(x); err != nil {
+		t.Fatalf("TryDeleteAgent() = %v, want = nil", err)
+	}
+	if err := db.TryDeleteAgent(x); !errors.Is(err, dberrors.ErrNotFound) {
+		t.Errorf("TryDeleteAgent() = %v, want = %v", err, dberrors.ErrNotFound)
+	}
+}
+
+func TestForEachAgent(t *testing.T) {
+	db := New(DefaultO)
+	for i := 0; i < 16; i++ {
+		db.InsertAgent(roagent.O{
+			Position:       vector.V{float64(i), 0},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		})
+	}
+
+	t.Run("Stop", func(t *testing.T) {
+		var n int
+		db.ForEachAgent(func(roagent.RO) bool {
+			n++
+			return n < 4
+		})
+		if n != 4 {
+			t.Errorf("ForEachAgent() visited %v agents, want = %v", n, 4)
+		}
+	})
+
+	t.Run("Allocs", func(t *testing.T) {
+		f := func(roagent.RO) bool { return true }
+		if got := testing.AllocsPerRun(16, func() { db.ForEachAgent(f) }); got != 0 {
+			t.Errorf("ForEachAgent() allocated %v times, want = 0", got)
+		}
+	})
+}
+
+func TestListAgentsContext(t *testing.T) {
+	db := New(DefaultO)
+	for i := 0; i < 1024; i++ {
+		db.InsertAgent(roagent.O{
+			Position:       vector.V{float64(i), 0},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := db.ListAgentsContext(ctx)
+	<-ch
+	cancel()
+
+	// The producer must close the channel after cancellation before all
+	// agents are sent. Once the buffer has room, the producer selects
+	// between the send and the cancellation at random, so the chance of
+	// sending all remaining agents is negligible.
+	got := 1
+	for range ch {
+		got++
+	}
+	if want := db.agents.Len(); got >= want {
+		t.Errorf("len(ListAgentsContext()) = %v, want < %v", got, want)
+	}
+}
+
+func TestQueryProjectiles(t *testing.T) {
+	db := New(DefaultO)
+	p := db.InsertProjectile(roprojectile.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+	})
+	q := *hyperrectangle.New(vector.V{9, 9}, vector.V{11, 11})
+	all := func(roprojectile.RO) bool { return true }
+
+	if got := db.QueryProjectiles(q, all); len(got) != 0 {
+		t.Errorf("QueryProjectiles() = %v, want = []", got)
+	}
+
+	db.SetProjectilePosition(p.ID(), vector.V{10, 10})
+	if got := db.QueryProjectiles(q, all); len(got) != 1 || got[0].ID() != p.ID() {
+		t.Errorf("QueryProjectiles() = %v, want = [%v]", got, p)
+	}
+
+	db.DeleteProjectile(p.ID())
+	if got := db.QueryProjectiles(q, all); len(got) != 0 {
+		t.Errorf("QueryProjectiles() = %v, want = []", got)
+	}
+}
+
+func TestSetFeatureAABB(t *testing.T) {
+	db := New(DefaultO)
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+	})
+	q := *hyperrectangle.New(vector.V{9, 9}, vector.V{11, 11})
+	all := func(rofeature.RO) bool { return true }
+
+	if got := db.QueryFeatures(q, all); len(got) != 0 {
+		t.Errorf("QueryFeatures() = %v, want = []", got)
+	}
+
+	db.SetFeatureAABB(f.ID(), *hyperrectangle.New(vector.V{8, 8}, vector.V{10, 10}))
+	if got := db.QueryFeatures(q, all); len(got) != 1 || got[0].ID() != f.ID() {
+		t.Errorf("QueryFeatures() = %v, want = [%v]", got, f)
+	}
+	if err := db.TrySetFeatureFlags(f.ID(), flags.FTerrainAir); !errors.Is(err, dberrors.ErrInvalidOptions) {
+		t.Errorf("TrySetFeatureFlags() = %v, want = %v", err, dberrors.ErrInvalidOptions)
+	}
+}
+
+func TestQueryInRadius(t *testing.T) {
+	db := New(DefaultO)
+	for _, p := range []vector.V{{0, 0}, {3, 0}, {2, 2}} {
+		db.InsertAgent(roagent.O{
+			Position:       p,
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		})
+	}
+	db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{1.5, 1.5}, vector.V{2, 2}),
+	})
+
+	// The agent at (2, 2) overlaps the query AABB but not the query
+	// circle, as its closest point is √8 - 1 ≈ 1.83 > 1.5 away.
+	if got := db.QueryAgentsInRadius(vector.V{0, 0}, 1.5, func(roagent.RO) bool { return true }); len(got) != 1 {
+		t.Errorf("QueryAgentsInRadius() = %v, want 1 agent", got)
+	}
+	if got := db.QueryAgentsInRadius(vector.V{0, 0}, 2, func(roagent.RO) bool { return true }); len(got) != 3 {
+		t.Errorf("QueryAgentsInRadius() = %v, want 3 agents", got)
+	}
+	if got := db.QueryFeaturesInRadius(vector.V{0, 0}, 2, func(rofeature.RO) bool { return true }); len(got) != 0 {
+		t.Errorf("QueryFeaturesInRadius() = %v, want = []", got)
+	}
+	if got := db.QueryFeaturesInRadius(vector.V{0, 0}, 2.2, func(rofeature.RO) bool { return true }); len(got) != 1 {
+		t.Errorf("QueryFeaturesInRadius() = %v, want 1 feature", got)
+	}
+}
+
+// broken is a spatial index which rejects all updates.
+type broken struct {
+	container.C
+}
+
+func (broken) Update(x id.ID, aabb hnd.R) error { return fmt.Errorf("cannot update %v", x) }
+
+func TestTrySetPositionRestore(t *testing.T) {
+	db := New(DefaultO)
+	db.agents = table.New[*agent.A](table.O{Name: "agent", Index: broken{DefaultO.bvh()}})
+	db.features = table.New[*feature.F](table.O{Name: "feature", Index: broken{DefaultO.bvh()}})
+	db.projectiles = table.New[*projectile.P](table.O{Name: "projectile", Index: broken{DefaultO.bvh()}})
+
+	a := db.InsertAgent(roagent.O{
+		Position:       vector.V{1, 1},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		Size:           size.FSmall,
+	})
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+	})
+	p := db.InsertProjectile(roprojectile.O{
+		Position:       vector.V{1, 1},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+	})
+
+	type config struct {
+		name string
+		f    func() error
+		got  func() string
+		want string
+	}
+
+	configs := []config{
+		{
+			name: "Agent",
+			f:    func() error { return db.TrySetAgentPosition(a.ID(), vector.V{10, 10}) },
+			got:  func() string { return fmt.Sprint(a.Position()) },
+			want: fmt.Sprint(vector.V{1, 1}),
+		},
+		{
+			name: "Feature",
+			f: func() error {
+				return db.TrySetFeatureAABB(f.ID(), *hyperrectangle.New(vector.V{10, 10}, vector.V{11, 11}))
+			},
+			got:  func() string { return fmt.Sprint(f.AABB()) },
+			want: fmt.Sprint(*hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1})),
+		},
+		{
+			name: "Projectile",
+			f:    func() error { return db.TrySetProjectilePosition(p.ID(), vector.V{10, 10}) },
+			got:  func() string { return fmt.Sprint(p.Position()) },
+			want: fmt.Sprint(vector.V{1, 1}),
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.f(); !errors.Is(err, dberrors.ErrIndex) {
+				t.Errorf("f() = %v, want = %v", err, dberrors.ErrIndex)
+			}
+			if got := c.got(); got != c.want {
+				t.Errorf("got = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/errors"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+)
+
+const (
+	// kindShift is the offset of the entity kind within an ID. The
+	// remaining low bits are allocated from the DB counter.
+	kindShift = 56
+)
+
+// Kind is the type of an entity, and is encoded in the high bits of the
+// entity ID.
+type Kind uint8
+
+const (
+	KindUnknown Kind = iota
+	KindAgent
+	KindFeature
+	KindProjectile
+
+	// KindUser is the kind of the first user-registered table. Subsequent
+	// tables are allocated increasing kinds.
+	KindUser
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindUnknown:
+		return "unknown"
+	case KindAgent:
+		return "agent"
+	case KindFeature:
+		return "feature"
+	case KindProjectile:
+		return "projectile"
+	default:
+		return fmt.Sprintf("user(%d)", uint8(k-KindUser))
+	}
+}
+
+// KindOf returns the kind encoded in the input ID. The entity is not
+// guaranteed to exist.
+func KindOf(x id.ID) Kind { return Kind(x >> kindShift) }
+
+// ID is an entity ID which carries the read-only type of the entity, e.g.
+// ID[roagent.RO]. Using typed IDs with Get allows the compiler to catch
+// callers which mix up e.g. agent and feature IDs.
+type ID[T any] id.ID
+
+// TypedID returns the typed ID of the input entity, e.g.
+//
+//	x := TypedID(db.InsertAgent(o))  // ID[roagent.RO]
+func TypedID[T interface{ ID() id.ID }](e T) ID[T] { return ID[T](e.ID()) }
+
+// Kind returns the kind of the entity with the input ID.
+//
+// Kind is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (db *DB) Kind(x id.ID) (Kind, error) {
+	var ok bool
+	switch k := KindOf(x); k {
+	case KindAgent:
+		db.agentsL.RLock()
+		ok = db.agents.At(x) != nil
+		db.agentsL.RUnlock()
+	case KindFeature:
+		db.featuresL.RLock()
+		ok = db.features.At(x) != nil
+		db.featuresL.RUnlock()
+	case KindProjectile:
+		db.projectilesL.RLock()
+		ok = db.projectiles.At(x) != nil
+		db.projectilesL.RUnlock()
+	default:
+		if t := db.table(k); t != nil {
+			ok = t.has(x)
+		}
+	}
+
+	if !ok {
+		return KindUnknown, fmt.Errorf("cannot find entity %v: %w", x, errors.ErrNotFound)
+	}
+	return KindOf(x), nil
+}
+
+// Get returns the entity with the input typed ID. T must be one of the
+// read-only entity interfaces (e.g. roagent.RO), or the entity type of a
+// user-registered table.
+//
+// Get is a read-only operation and may be called concurrently with other
+// read-only operations.
+func Get[T table.E](db RO, x ID[T]) (T, error) {
+	var zero T
+	var v any
+	var err error
+
+	switch any((*T)(nil)).(type) {
+	case *roagent.RO:
+		v, err = db.GetAgent(id.ID(x))
+	case *rofeature.RO:
+		v, err = db.GetFeature(id.ID(x))
+	case *roprojectile.RO:
+		v, err = db.GetProjectile(id.ID(x))
+	default:
+		d, ok := db.(*DB)
+		if !ok {
+			return zero, fmt.Errorf("cannot get entity %v of type %T: %w", x, (*T)(nil), errors.ErrInvalidOptions)
+		}
+		t, ok := d.table(KindOf(id.ID(x))).(*Table[T])
+		if !ok {
+			return zero, fmt.Errorf("cannot find entity %v of type %T: %w", x, (*T)(nil), errors.ErrNotFound)
+		}
+		return t.Get(id.ID(x))
+	}
+
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// GetOrDie is a read-only operation and may be called concurrently with other
+// read-only operations.
+func GetOrDie[T table.E](db RO, x ID[T]) T {
+	v, err := Get(db, x)
+	if err != nil {
+		panic(err.Error())
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/cache"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+)
+
+func TestKind(t *testing.T) {
+	db := New(DefaultO)
+	pickups := RegisterTable[*pickup](db, "pickup")
+
+	a := db.InsertAgent(roagent.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		Size:           size.FSmall,
+	})
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+	})
+	p := pickups.Insert(func(x id.ID) *pickup { return &pickup{id: x, p: vector.V{0, 0}} })
+	deleted := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+	}).ID()
+	db.DeleteFeature(deleted)
+
+	type config struct {
+		name    string
+		x       id.ID
+		want    Kind
+		wantErr error
+	}
+
+	configs := []config{
+		{name: "Agent", x: a.ID(), want: KindAgent},
+		{name: "Feature", x: f.ID(), want: KindFeature},
+		{name: "User", x: p.ID(), want: KindUser},
+		{name: "Deleted", x: deleted, want: KindUnknown, wantErr: dberrors.ErrNotFound},
+		{name: "Unknown", x: 100, want: KindUnknown, wantErr: dberrors.ErrNotFound},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := db.Kind(c.x)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Kind() = _, %v, want = _, %v", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("Kind() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		if got := GetOrDie(db, TypedID(a)); got != a {
+			t.Errorf("Get() = %v, want = %v", got, a)
+		}
+		if got := GetOrDie(db, TypedID(f)); got != f {
+			t.Errorf("Get() = %v, want = %v", got, f)
+		}
+		if got := GetOrDie(db, TypedID(p)); got != p {
+			t.Errorf("Get() = %v, want = %v", got, p)
+		}
+		if _, err := Get(db, ID[roagent.RO](f.ID())); !errors.Is(err, dberrors.ErrNotFound) {
+			t.Errorf("Get() = _, %v, want = _, %v", err, dberrors.ErrNotFound)
+		}
+		if _, err := Get(db, ID[*pickup](a.ID())); !errors.Is(err, dberrors.ErrNotFound) {
+			t.Errorf("Get() = _, %v, want = _, %v", err, dberrors.ErrNotFound)
+		}
+	})
+
+	t.Run("Get/Cache", func(t *testing.T) {
+		c := cache.New(cache.O{Agents: []roagent.RO{a}})
+		if got := GetOrDie(c, TypedID(a)); got != a {
+			t.Errorf("Get() = %v, want = %v", got, a)
+		}
+	})
+}
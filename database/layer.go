@@ -0,0 +1,105 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-database/filters"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/internal/agent"
+
+	roagent "github.com/downflux/go-database/agent"
+)
+
+// layered is a view of an agent with a different set of flags, used to check
+// for obstructions before committing a layer transition.
+type layered struct {
+	roagent.RO
+	flags flags.F
+}
+
+func (a layered) Flags() flags.F { return a.flags }
+
+// TrySetAgentFlags sets the terrain flags of the agent, which must occupy
+// exactly one map layer (see flags.ValidateStrict). Agents may be inserted
+// without an occupied layer (see flags.Validate); setting the current flags of
+// such an agent is a no-op.
+//
+// Moving the agent out of the air layer (e.g. landing) is rejected with
+// errors.ErrBlocked if the agent would then collide with another agent or a
+// feature on the new layer.
+//
+// TrySetAgentFlags reads the state of other agents and must be called
+// serially.
+func (db *DB) TrySetAgentFlags(x id.ID, f flags.F) error {
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+	return db.setAgentFlags(a, f)
+}
+
+// TrySetAgentLayer moves the agent onto the input map layer, e.g.
+// flags.FTerrainAir, while preserving its terrain access flags. The same
+// restrictions as TrySetAgentFlags apply.
+//
+// TrySetAgentLayer reads the state of other agents and must be called
+// serially.
+func (db *DB) TrySetAgentLayer(x id.ID, l flags.F) error {
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	a, err := db.agents.Get(x)
+	if err != nil {
+		return err
+	}
+	if l&^flags.TerrainLayers != 0 {
+		return fmt.Errorf("cannot set layer %v for agent %v: %w", l, x, errors.ErrInvalidOptions)
+	}
+	return db.setAgentFlags(a, a.Flags()&^flags.TerrainLayers|l)
+}
+
+// SetAgentFlags reads the state of other agents and must be called serially.
+func (db *DB) SetAgentFlags(x id.ID, f flags.F) { die(db.TrySetAgentFlags(x, f)) }
+
+// SetAgentLayer reads the state of other agents and must be called serially.
+func (db *DB) SetAgentLayer(x id.ID, l flags.F) { die(db.TrySetAgentLayer(x, l)) }
+
+// setAgentFlags validates and commits the flags transition. The caller must
+// hold the agent write lock and the feature read lock.
+func (db *DB) setAgentFlags(a *agent.A, f flags.F) error {
+	if f == a.Flags() {
+		return nil
+	}
+	if !flags.ValidateStrict(f) {
+		return fmt.Errorf("cannot set flags %v for agent %v: %w", f, a.ID(), errors.ErrInvalidOptions)
+	}
+
+	if l := flags.Layer(f); l != flags.Layer(a.Flags()) && l != flags.FTerrainAir {
+		v := layered{RO: a, flags: f}
+		for _, y := range db.agents.BroadPhase(a.AABB()) {
+			if b := db.agents.At(y); filters.AgentIsColliding(v, b) {
+				return fmt.Errorf("cannot set flags %v for agent %v: %w by agent %v", f, a.ID(), errors.ErrBlocked, y)
+			}
+		}
+		for _, y := range db.features.BroadPhase(a.AABB()) {
+			if g := db.features.At(y); filters.AgentIsCollidingWithFeature(v, g) {
+				return fmt.Errorf("cannot set flags %v for agent %v: %w by feature %v", f, a.ID(), errors.ErrBlocked, y)
+			}
+		}
+	}
+
+	db.preserveAgent(a)
+	a.SetFlags(f)
+	return nil
+}
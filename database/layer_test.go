@@ -0,0 +1,234 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+)
+
+func TestTrySetAgentLayer(t *testing.T) {
+	const (
+		aircraft = flags.FTerrainAccessibleAir | flags.FTerrainAccessibleLand
+	)
+
+	o := func(p vector.V, l flags.F) roagent.O {
+		return roagent.O{
+			Position:       p,
+			TargetPosition: p,
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+			Flags:          aircraft | l,
+		}
+	}
+
+	type config struct {
+		name     string
+		agents   []roagent.O
+		features []rofeature.O
+		layer    flags.F
+		f        func(db *DB, x id.ID) error
+		want     error
+		// got is the expected occupied layer of the agent under test
+		// after the call.
+		got flags.F
+	}
+
+	configs := []config{
+		{
+			name:  "Takeoff",
+			layer: flags.FTerrainLand,
+			f:     func(db *DB, x id.ID) error { return db.TrySetAgentLayer(x, flags.FTerrainAir) },
+			want:  nil,
+			got:   flags.FTerrainAir,
+		},
+		{
+			name:  "Land",
+			layer: flags.FTerrainAir,
+			f:     func(db *DB, x id.ID) error { return db.TrySetAgentLayer(x, flags.FTerrainLand) },
+			want:  nil,
+			got:   flags.FTerrainLand,
+		},
+		{
+			name:  "Land/Inaccessible",
+			layer: flags.FTerrainAir,
+			f:     func(db *DB, x id.ID) error { return db.TrySetAgentLayer(x, flags.FTerrainSea) },
+			want:  dberrors.ErrInvalidOptions,
+			got:   flags.FTerrainAir,
+		},
+		{
+			name:   "Land/BlockedByAgent",
+			agents: []roagent.O{o(vector.V{1, 0}, flags.FTerrainLand)},
+			layer:  flags.FTerrainAir,
+			f:      func(db *DB, x id.ID) error { return db.TrySetAgentLayer(x, flags.FTerrainLand) },
+			want:   dberrors.ErrBlocked,
+			got:    flags.FTerrainAir,
+		},
+		{
+			name:   "Land/IgnoreAirAgent",
+			agents: []roagent.O{o(vector.V{1, 0}, flags.FTerrainAir)},
+			layer:  flags.FTerrainAir,
+			f:      func(db *DB, x id.ID) error { return db.TrySetAgentLayer(x, flags.FTerrainLand) },
+			want:   nil,
+			got:    flags.FTerrainLand,
+		},
+		{
+			name: "Land/BlockedByFeature",
+			features: []rofeature.O{
+				{AABB: *hyperrectangle.New(vector.V{0.5, -1}, vector.V{2, 1})},
+			},
+			layer: flags.FTerrainAir,
+			f:     func(db *DB, x id.ID) error { return db.TrySetAgentLayer(x, flags.FTerrainLand) },
+			want:  dberrors.ErrBlocked,
+			got:   flags.FTerrainAir,
+		},
+		{
+			name:  "Tx/Land",
+			layer: flags.FTerrainAir,
+			f: func(db *DB, x id.ID) error {
+				tx := db.Begin()
+				tx.SetAgentLayer(x, flags.FTerrainLand)
+				return tx.Commit()
+			},
+			want: nil,
+			got:  flags.FTerrainLand,
+		},
+		{
+			name:   "Tx/Land/BlockedByAgent",
+			agents: []roagent.O{o(vector.V{1, 0}, flags.FTerrainLand)},
+			layer:  flags.FTerrainAir,
+			f: func(db *DB, x id.ID) error {
+				tx := db.Begin()
+				tx.SetAgentFlags(x, aircraft|flags.FTerrainLand)
+				return tx.Commit()
+			},
+			want: dberrors.ErrBlocked,
+			got:  flags.FTerrainAir,
+		},
+		{
+			// The agent may land once the blocking agent has moved
+			// away earlier in the same Tx.
+			name:   "Tx/Land/BlockerMoved",
+			agents: []roagent.O{o(vector.V{1, 0}, flags.FTerrainLand)},
+			layer:  flags.FTerrainAir,
+			f: func(db *DB, x id.ID) error {
+				tx := db.Begin()
+				for _, b := range db.QueryAgents(*hyperrectangle.New(vector.V{1, 0}, vector.V{1, 0}), func(b roagent.RO) bool { return b.ID() != x }) {
+					tx.SetAgentPosition(b.ID(), vector.V{10, 0})
+				}
+				tx.SetAgentLayer(x, flags.FTerrainLand)
+				return tx.Commit()
+			},
+			want: nil,
+			got:  flags.FTerrainLand,
+		},
+		{
+			// The agent may not land once another agent has moved
+			// underneath it earlier in the same Tx.
+			name:   "Tx/Land/BlockerMovedIn",
+			agents: []roagent.O{o(vector.V{10, 0}, flags.FTerrainLand)},
+			layer:  flags.FTerrainAir,
+			f: func(db *DB, x id.ID) error {
+				tx := db.Begin()
+				for _, b := range db.QueryAgents(*hyperrectangle.New(vector.V{10, 0}, vector.V{10, 0}), func(b roagent.RO) bool { return b.ID() != x }) {
+					tx.SetAgentPosition(b.ID(), vector.V{1, 0})
+				}
+				tx.SetAgentLayer(x, flags.FTerrainLand)
+				return tx.Commit()
+			},
+			want: dberrors.ErrBlocked,
+			got:  flags.FTerrainAir,
+		},
+		{
+			name:  "Tx/Land/Inaccessible",
+			layer: flags.FTerrainAir,
+			f: func(db *DB, x id.ID) error {
+				tx := db.Begin()
+				tx.SetAgentLayer(x, flags.FTerrainSea)
+				return tx.Commit()
+			},
+			want: dberrors.ErrInvalidOptions,
+			got:  flags.FTerrainAir,
+		},
+		{
+			// The agent was inserted without an occupied layer, and
+			// must be restored to that state on rollback.
+			name:  "Tx/Rollback/NoLayer",
+			layer: flags.FNone,
+			f: func(db *DB, x id.ID) error {
+				tx := db.Begin()
+				tx.SetAgentPosition(x, vector.V{5, 5})
+				tx.SetAgentLayer(x, flags.FTerrainAir)
+				tx.SetAgentPosition(x+1000, vector.V{0, 0})
+				err := tx.Commit()
+				if got, want := db.GetAgentOrDie(x).Position(), (vector.V{0, 0}); !vector.Within(got, want) {
+					return fmt.Errorf("Position() = %v, want = %v", got, want)
+				}
+				return err
+			},
+			want: dberrors.ErrNotFound,
+			got:  flags.FNone,
+		},
+		{
+			// Agents inserted without an occupied layer may keep
+			// their current flags.
+			name:  "SetFlags/NoLayer/Unchanged",
+			layer: flags.FNone,
+			f:     func(db *DB, x id.ID) error { return db.TrySetAgentFlags(x, aircraft) },
+			want:  nil,
+			got:   flags.FNone,
+		},
+		{
+			name:  "SetFlags/NoLayer/Changed",
+			layer: flags.FNone,
+			f: func(db *DB, x id.ID) error {
+				return db.TrySetAgentFlags(x, flags.FTerrainAccessibleLand)
+			},
+			want: dberrors.ErrInvalidOptions,
+			got:  flags.FNone,
+		},
+		{
+			name:  "SetFlags/MultipleLayers",
+			layer: flags.FTerrainAir,
+			f: func(db *DB, x id.ID) error {
+				return db.TrySetAgentFlags(x, aircraft|flags.FTerrainAir|flags.FTerrainLand)
+			},
+			want: dberrors.ErrInvalidOptions,
+			got:  flags.FTerrainAir,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := New(DefaultO)
+			for _, o := range c.agents {
+				db.InsertAgent(o)
+			}
+			for _, o := range c.features {
+				db.InsertFeature(o)
+			}
+			a := db.InsertAgent(o(vector.V{0, 0}, c.layer))
+
+			if err := c.f(db, a.ID()); !errors.Is(err, c.want) {
+				t.Errorf("f() = %v, want = %v", err, c.want)
+			}
+			if got := flags.Layer(a.Flags()); got != c.got {
+				t.Errorf("Layer() = %v, want = %v", got, c.got)
+			}
+		})
+	}
+}
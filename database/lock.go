@@ -0,0 +1,62 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/downflux/go-bvh/id"
+)
+
+const (
+	// nGuards is the number of striped per-entity guards. Two entities
+	// share a guard iff their IDs are equal modulo nGuards, which bounds
+	// the memory overhead of the guards independent of the number of
+	// entities in the DB.
+	nGuards = 256
+)
+
+// rw guards an entity map and its associated BVH.
+type rw interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// nop is a lock which does nothing, and is used when the DB is not set up for
+// concurrent access.
+type nop struct{}
+
+func (nop) Lock()    {}
+func (nop) Unlock()  {}
+func (nop) RLock()   {}
+func (nop) RUnlock() {}
+
+func newRW(concurrent bool) rw {
+	if concurrent {
+		return &sync.RWMutex{}
+	}
+	return nop{}
+}
+
+// guards serializes non-BVH mutations on a single entity. A nil guards is a
+// no-op.
+type guards []sync.Mutex
+
+func newGuards(concurrent bool) guards {
+	if concurrent {
+		return make([]sync.Mutex, nGuards)
+	}
+	return nil
+}
+
+func (g guards) Lock(x id.ID) {
+	if g != nil {
+		g[x%nGuards].Lock()
+	}
+}
+
+func (g guards) Unlock(x id.ID) {
+	if g != nil {
+		g[x%nGuards].Unlock()
+	}
+}
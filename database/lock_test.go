@@ -0,0 +1,114 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+)
+
+// TestConcurrent exercises the concurrency contract of a DB created with
+// O.Concurrent set. This test is most useful when run with the -race flag.
+func TestConcurrent(t *testing.T) {
+	const (
+		n       = 64
+		workers = 8
+		ticks   = 32
+	)
+
+	o := DefaultO
+	o.Concurrent = true
+	db := New(o)
+
+	xs := make([]id.ID, 0, n)
+	for i := 0; i < n; i++ {
+		xs = append(xs, db.InsertAgent(roagent.O{
+			Position:       vector.V{float64(i), 0},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		}).ID())
+	}
+
+	var wg sync.WaitGroup
+
+	// Per-agent mutations which do not touch the BVH.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ticks; i++ {
+				for _, x := range xs {
+					db.SetAgentVelocity(x, vector.V{float64(w), float64(i)})
+					db.SetAgentTargetVelocity(x, vector.V{float64(i), float64(w)})
+					db.SetAgentHeading(x, polar.V{1, float64(i)})
+				}
+			}
+		}(w)
+	}
+
+	// BVH mutations.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ticks; i++ {
+				for j := w; j < len(xs); j += workers {
+					db.SetAgentPosition(xs[j], vector.V{float64(i), float64(j)})
+				}
+				x := db.InsertAgent(roagent.O{
+					Position:       vector.V{float64(i), float64(w)},
+					TargetPosition: vector.V{0, 0},
+					Velocity:       vector.V{0, 0},
+					TargetVelocity: vector.V{0, 0},
+					Heading:        polar.V{1, 0},
+					Radius:         1,
+					Mass:           1,
+					Size:           size.FSmall,
+				}).ID()
+				db.DeleteAgent(x)
+			}
+		}(w)
+	}
+
+	// Read-only operations.
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < ticks; i++ {
+				db.QueryAgents(*hyperrectangle.New(
+					vector.V{0, 0},
+					vector.V{n, n},
+				), func(a roagent.RO) bool { return a.ID()%2 == 0 })
+				for _, x := range xs {
+					if _, err := db.GetAgent(x); err != nil {
+						panic(fmt.Sprintf("GetAgent() = %v, want = nil", err))
+					}
+				}
+				for range db.ListAgents() {
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := len(db.QueryAgents(*hyperrectangle.New(
+		vector.V{-n, -n},
+		vector.V{2 * n, 2 * n},
+	), func(roagent.RO) bool { return true })); got != n {
+		t.Errorf("QueryAgents() = %v agents, want = %v", got, n)
+	}
+}
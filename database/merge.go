@@ -0,0 +1,256 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-database/internal/agent"
+	"github.com/downflux/go-database/internal/projectile"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// Field is a vector-valued entity field which may be targeted by a proposal.
+type Field uint8
+
+const (
+	FieldAgentPosition Field = iota
+	FieldAgentTargetPosition
+	FieldAgentVelocity
+	FieldAgentTargetVelocity
+	FieldProjectilePosition
+	FieldProjectileTargetPosition
+	FieldProjectileVelocity
+	FieldProjectileTargetVelocity
+)
+
+func (f Field) String() string {
+	switch f {
+	case FieldAgentPosition:
+		return "agent position"
+	case FieldAgentTargetPosition:
+		return "agent target position"
+	case FieldAgentVelocity:
+		return "agent velocity"
+	case FieldAgentTargetVelocity:
+		return "agent target velocity"
+	case FieldProjectilePosition:
+		return "projectile position"
+	case FieldProjectileTargetPosition:
+		return "projectile target position"
+	case FieldProjectileVelocity:
+		return "projectile velocity"
+	case FieldProjectileTargetVelocity:
+		return "projectile target velocity"
+	default:
+		return fmt.Sprintf("unknown field %d", uint8(f))
+	}
+}
+
+// Proposal is a proposed write to an entity field.
+type Proposal struct {
+	ID    id.ID
+	Field Field
+	Value vector.V
+
+	// Priority is consulted by PolicyPriority. Larger values take
+	// precedence.
+	Priority int
+
+	// Source identifies the proposing system, e.g. "knockback", and is
+	// only used for reporting conflicts.
+	Source string
+}
+
+// Conflict is the set of proposals which write to the same field of the same
+// entity within a single merge. Proposals are listed in the order they were
+// proposed.
+type Conflict struct {
+	ID        id.ID
+	Field     Field
+	Proposals []Proposal
+}
+
+// Policy resolves a set of conflicting proposals into a single value. The
+// input base is the value of the field in the DB at the time the merged Tx is
+// committed, i.e. includes any writes made between Merge and Commit.
+//
+// Policies are called while Commit holds exclusive access to the DB, and must
+// not call into the DB.
+type Policy func(base vector.V, ps []Proposal) vector.V
+
+var (
+	// PolicyPriority takes the value of the proposal with the highest
+	// priority. Ties are broken in favor of the later proposal.
+	PolicyPriority Policy = func(base vector.V, ps []Proposal) vector.V {
+		p := ps[0]
+		for _, q := range ps[1:] {
+			if q.Priority >= p.Priority {
+				p = q
+			}
+		}
+		return p.Value
+	}
+
+	// PolicyLastWriter takes the value of the last proposal. Note that if
+	// proposals are made concurrently, the order of proposals (and
+	// therefore the result) is not deterministic.
+	PolicyLastWriter Policy = func(base vector.V, ps []Proposal) vector.V {
+		return ps[len(ps)-1].Value
+	}
+
+	// PolicySumOfDeltas applies the change proposed by each proposal
+	// relative to the current value of the field, e.g. an agent which is
+	// both moved by 1 and knocked back by 2 along the X-axis will move by
+	// 3.
+	PolicySumOfDeltas Policy = func(base vector.V, ps []Proposal) vector.V {
+		v := vector.M{base.X(), base.Y()}
+		for _, p := range ps {
+			v.Add(vector.Sub(p.Value, base))
+		}
+		return v.V()
+	}
+)
+
+type key struct {
+	id    id.ID
+	field Field
+}
+
+// Merger collects proposals from systems which run in parallel, and merges
+// them into a single Tx.
+//
+// Propose may be called concurrently.
+type Merger struct {
+	mu        sync.Mutex
+	proposals map[key][]Proposal
+}
+
+func NewMerger() *Merger {
+	return &Merger{
+		proposals: make(map[key][]Proposal, 1024),
+	}
+}
+
+// Propose records a proposed write.
+func (m *Merger) Propose(p Proposal) {
+	p.Value = clone(p.Value)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := key{id: p.ID, field: p.Field}
+	m.proposals[k] = append(m.proposals[k], p)
+}
+
+// Merge resolves all recorded proposals with the input policy, and buffers
+// the resulting writes into a new Tx on the DB, which the caller must commit.
+// All fields with more than one proposal are reported as conflicts, ordered
+// by entity ID and field. Fields with a single proposal are written as-is.
+//
+// Conflicts are resolved when the Tx is committed, rather than when Merge is
+// called, so that the policy observes the current value of each field.
+//
+// Merge clears the recorded proposals, i.e. the Merger may be reused for the
+// next tick.
+func (m *Merger) Merge(db *DB, policy Policy) (*Tx, []Conflict) {
+	m.mu.Lock()
+	proposals := m.proposals
+	m.proposals = make(map[key][]Proposal, len(proposals))
+	m.mu.Unlock()
+
+	keys := make([]key, 0, len(proposals))
+	for k := range proposals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].id != keys[j].id {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].field < keys[j].field
+	})
+
+	tx := db.Begin()
+	var conflicts []Conflict
+	for _, k := range keys {
+		ps := proposals[k]
+		if len(ps) == 1 {
+			k.field.set(tx, k.id, ps[0].Value)
+			continue
+		}
+
+		conflicts = append(conflicts, Conflict{
+			ID:        k.id,
+			Field:     k.field,
+			Proposals: ps,
+		})
+		k.field.update(tx, k.id, func(base vector.V) vector.V { return policy(base, ps) })
+	}
+	return tx, conflicts
+}
+
+// update buffers a write to the field of the entity, whose value is
+// calculated from the current value of the field when the Tx is committed.
+func (f Field) update(tx *Tx, x id.ID, fn func(base vector.V) vector.V) {
+	switch f {
+	case FieldAgentPosition:
+		tx.agent(x, true, updateAgent((*agent.A).Position, (*agent.A).SetPosition, fn))
+	case FieldAgentTargetPosition:
+		tx.agent(x, false, updateAgent((*agent.A).TargetPosition, (*agent.A).SetTargetPosition, fn))
+	case FieldAgentVelocity:
+		tx.agent(x, false, updateAgent((*agent.A).Velocity, (*agent.A).SetVelocity, fn))
+	case FieldAgentTargetVelocity:
+		tx.agent(x, false, updateAgent((*agent.A).TargetVelocity, (*agent.A).SetTargetVelocity, fn))
+	case FieldProjectilePosition:
+		tx.projectile(x, true, updateProjectile((*projectile.P).Position, (*projectile.P).SetPosition, fn))
+	case FieldProjectileTargetPosition:
+		tx.projectile(x, false, updateProjectile((*projectile.P).TargetPosition, (*projectile.P).SetTargetPosition, fn))
+	case FieldProjectileVelocity:
+		tx.projectile(x, false, updateProjectile((*projectile.P).Velocity, (*projectile.P).SetVelocity, fn))
+	case FieldProjectileTargetVelocity:
+		tx.projectile(x, false, updateProjectile((*projectile.P).TargetVelocity, (*projectile.P).SetTargetVelocity, fn))
+	default:
+		tx.fail(fmt.Errorf("cannot set %v for entity %v: %w", f, x, errors.ErrInvalidOptions))
+	}
+}
+
+func updateAgent(get func(a *agent.A) vector.V, set func(a *agent.A, v vector.V), fn func(base vector.V) vector.V) func(a *agent.A) func() {
+	return func(a *agent.A) func() {
+		u := clone(get(a))
+		set(a, clone(fn(clone(u))))
+		return func() { set(a, u) }
+	}
+}
+
+func updateProjectile(get func(p *projectile.P) vector.V, set func(p *projectile.P, v vector.V), fn func(base vector.V) vector.V) func(p *projectile.P) func() {
+	return func(p *projectile.P) func() {
+		u := clone(get(p))
+		set(p, clone(fn(clone(u))))
+		return func() { set(p, u) }
+	}
+}
+
+func (f Field) set(tx *Tx, x id.ID, v vector.V) {
+	switch f {
+	case FieldAgentPosition:
+		tx.SetAgentPosition(x, v)
+	case FieldAgentTargetPosition:
+		tx.SetAgentTargetPosition(x, v)
+	case FieldAgentVelocity:
+		tx.SetAgentVelocity(x, v)
+	case FieldAgentTargetVelocity:
+		tx.SetAgentTargetVelocity(x, v)
+	case FieldProjectilePosition:
+		tx.SetProjectilePosition(x, v)
+	case FieldProjectileTargetPosition:
+		tx.SetProjectileTargetPosition(x, v)
+	case FieldProjectileVelocity:
+		tx.SetProjectileVelocity(x, v)
+	case FieldProjectileTargetVelocity:
+		tx.SetProjectileTargetVelocity(x, v)
+	default:
+		tx.fail(fmt.Errorf("cannot set %v for entity %v: %w", f, x, errors.ErrInvalidOptions))
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+)
+
+func TestMerge(t *testing.T) {
+	type config struct {
+		name   string
+		policy Policy
+		want   vector.V
+	}
+
+	configs := []config{
+		{name: "Priority", policy: PolicyPriority, want: vector.V{0, 5}},
+		{name: "LastWriter", policy: PolicyLastWriter, want: vector.V{-1, 0}},
+		{name: "SumOfDeltas", policy: PolicySumOfDeltas, want: vector.V{2, 6}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := New(DefaultO)
+			var agents []roagent.RO
+			for i := 0; i < 2; i++ {
+				agents = append(agents, db.InsertAgent(roagent.O{
+					Position:       vector.V{float64(10 * i), 0},
+					TargetPosition: vector.V{0, 0},
+					Velocity:       vector.V{0, 0},
+					TargetVelocity: vector.V{0, 0},
+					Heading:        polar.V{1, 0},
+					Radius:         1,
+					Mass:           1,
+					Size:           size.FSmall,
+				}))
+			}
+			a, b := agents[0], agents[1]
+
+			m := NewMerger()
+			m.Propose(Proposal{ID: a.ID(), Field: FieldAgentPosition, Value: vector.V{3, 1}, Source: "movement"})
+			m.Propose(Proposal{ID: a.ID(), Field: FieldAgentPosition, Value: vector.V{0, 5}, Priority: 1, Source: "teleport"})
+			m.Propose(Proposal{ID: a.ID(), Field: FieldAgentPosition, Value: vector.V{-1, 0}, Source: "knockback"})
+			m.Propose(Proposal{ID: b.ID(), Field: FieldAgentPosition, Value: vector.V{11, 0}, Source: "movement"})
+
+			tx, conflicts := m.Merge(db, c.policy)
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit() = %v, want = nil", err)
+			}
+
+			if len(conflicts) != 1 || conflicts[0].ID != a.ID() || len(conflicts[0].Proposals) != 3 {
+				t.Errorf("Merge() = _, %v, want 1 conflict on agent %v", conflicts, a.ID())
+			}
+			if got := a.Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+			if got, want := b.Position(), (vector.V{11, 0}); !vector.Within(got, want) {
+				t.Errorf("Position() = %v, want = %v", got, want)
+			}
+
+			if tx, conflicts := m.Merge(db, c.policy); len(tx.ops) != 0 || len(conflicts) != 0 {
+				t.Errorf("Merge() = %v, %v, want an empty merge after reset", tx.ops, conflicts)
+			}
+		})
+	}
+
+	t.Run("WriteBeforeCommit", func(t *testing.T) {
+		db := New(DefaultO)
+		a := db.InsertAgent(roagent.O{
+			Position:       vector.V{0, 0},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		})
+
+		m := NewMerger()
+		m.Propose(Proposal{ID: a.ID(), Field: FieldAgentPosition, Value: vector.V{1, 0}, Source: "movement"})
+		m.Propose(Proposal{ID: a.ID(), Field: FieldAgentPosition, Value: vector.V{0, 2}, Source: "knockback"})
+
+		tx, _ := m.Merge(db, PolicySumOfDeltas)
+
+		// The deltas are taken relative to the value at commit time,
+		// i.e. (1, 0) - (10, 10) and (0, 2) - (10, 10).
+		db.SetAgentPosition(a.ID(), vector.V{10, 10})
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() = %v, want = nil", err)
+		}
+		if got, want := a.Position(), (vector.V{-9, -8}); !vector.Within(got, want) {
+			t.Errorf("Position() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		db := New(DefaultO)
+		a := db.InsertAgent(roagent.O{
+			Position:       vector.V{0, 0},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		})
+
+		m := NewMerger()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				m.Propose(Proposal{
+					ID:     a.ID(),
+					Field:  FieldAgentVelocity,
+					Value:  vector.V{1, 0},
+					Source: fmt.Sprintf("system-%v", i),
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		tx, _ := m.Merge(db, PolicySumOfDeltas)
+		tx.Commit()
+		if got, want := a.Velocity(), (vector.V{8, 0}); !vector.Within(got, want) {
+			t.Errorf("Velocity() = %v, want = %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/tree"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+)
+
+// hierarchy is implemented by spatial indexes which expose their node
+// hierarchy, e.g. tree.T.
+type hierarchy interface {
+	Root() *tree.N
+}
+
+// NearestAgents returns up to k agents which pass the input filter, sorted by
+// the distance from p to the agent's circle. Agents whose circle contains p
+// are at distance zero. Ties are broken by agent ID.
+//
+// The search is a best-first traversal of the BVH, which visits nodes in order
+// of the distance from p to the node AABB, and stops as soon as the k-th
+// closest agent is no farther than the next unvisited node.
+//
+// NearestAgents is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) NearestAgents(p vector.V, k int, filter func(a roagent.RO) bool) []roagent.RO {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.NearestAgents(p, k, filter)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	return nearest(db.agents.Index(), func(x id.ID) roagent.RO { return db.agents.At(x) }, p, k, DistanceAgent, filter)
+}
+
+// NearestFeatures returns up to k features which pass the input filter, sorted
+// by the distance from p to the feature's AABB. Features whose AABB contains p
+// are at distance zero. Ties are broken by feature ID.
+//
+// See NearestAgents for more information.
+func (db *DB) NearestFeatures(p vector.V, k int, filter func(f rofeature.RO) bool) []rofeature.RO {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.NearestFeatures(p, k, filter)
+	}
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	return nearest(db.features.Index(), func(x id.ID) rofeature.RO { return db.features.At(x) }, p, k, DistanceFeature, filter)
+}
+
+// DistanceAgent returns the distance from p to the circle of the agent. The
+// distance is zero if p lies within the circle.
+func DistanceAgent(a roagent.RO, p vector.V) float64 {
+	return math.Max(0, vector.Magnitude(vector.Sub(a.Position(), p))-a.Radius())
+}
+
+// DistanceFeature returns the distance from p to the AABB of the feature. The
+// distance is zero if p lies within the AABB.
+func DistanceFeature(f rofeature.RO, p vector.V) float64 {
+	if f.AABB().In(p) {
+		return 0
+	}
+	d, _ := dhr.Normal(f.AABB(), p)
+	return d
+}
+
+// nearest returns up to k entities in the input spatial index which pass the
+// input filter, sorted by distance and then by ID. The distance from p to an
+// entity must be no smaller than the distance from p to the entity AABB.
+//
+// If the index does not expose its node hierarchy, all entities are
+// considered.
+func nearest[V any](c container.C, at func(x id.ID) V, p vector.V, k int, distance func(v V, p vector.V) float64, filter func(v V) bool) []V {
+	if k <= 0 {
+		return nil
+	}
+
+	f := &frontier{}
+	if h, ok := c.(hierarchy); ok {
+		if n := h.Root(); n != nil {
+			heap.Push(f, candidate{d: distanceAABB(n.AABB(), p), n: n})
+		}
+	} else {
+		for _, x := range c.IDs() {
+			heap.Push(f, candidate{d: distance(at(x), p), x: x})
+		}
+	}
+
+	var results []V
+	for f.Len() > 0 && len(results) < k {
+		m := heap.Pop(f).(candidate)
+		switch {
+		case m.n == nil:
+			if v := at(m.x); filter(v) {
+				results = append(results, v)
+			}
+		case m.n.IsLeaf():
+			for _, x := range m.n.IDs() {
+				heap.Push(f, candidate{d: distance(at(x), p), x: x})
+			}
+		default:
+			for _, n := range []*tree.N{m.n.Left(), m.n.Right()} {
+				heap.Push(f, candidate{d: distanceAABB(n.AABB(), p), n: n})
+			}
+		}
+	}
+	return results
+}
+
+// candidate is an entry in the frontier of a best-first search, i.e. either a
+// BVH node or a single entity.
+type candidate struct {
+	// d is the distance from the search point to the entity, or a lower
+	// bound on the distance to any entity under the node.
+	d float64
+
+	n *tree.N
+	x id.ID
+}
+
+// frontier is a min-heap of candidates, and implements heap.Interface.
+type frontier []candidate
+
+func (f frontier) Len() int      { return len(f) }
+func (f frontier) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f frontier) Less(i, j int) bool {
+	a, b := f[i], f[j]
+	if a.d != b.d {
+		return a.d < b.d
+	}
+	// Nodes are expanded before entities at the same distance, as they
+	// may contain entities with smaller IDs.
+	if (a.n == nil) != (b.n == nil) {
+		return a.n != nil
+	}
+	return a.x < b.x
+}
+
+func (f *frontier) Push(x any) { *f = append(*f, x.(candidate)) }
+func (f *frontier) Pop() any {
+	g := *f
+	m := g[len(g)-1]
+	*f = g[:len(g)-1]
+	return m
+}
+
+// distanceAABB returns the distance from p to the input AABB. The distance is
+// zero if p lies within the AABB.
+func distanceAABB(r hyperrectangle.R, p vector.V) float64 {
+	dx := math.Max(0, math.Max(r.Min().X()-p.X(), p.X()-r.Max().X()))
+	dy := math.Max(0, math.Max(r.Min().Y()-p.Y(), p.Y()-r.Max().Y()))
+	return math.Hypot(dx, dy)
+}
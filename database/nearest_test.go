@@ -0,0 +1,164 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+)
+
+func TestNearestAgents(t *testing.T) {
+	db := New(DefaultO)
+
+	type agent struct {
+		p vector.V
+		r float64
+	}
+	var xs []id.ID
+	for _, a := range []agent{
+		{p: vector.V{100, 0}, r: 1},
+		{p: vector.V{10, 0}, r: 1},
+		{p: vector.V{0, 12}, r: 5},
+		{p: vector.V{-50, -50}, r: 1},
+		{p: vector.V{1000, 1000}, r: 1},
+	} {
+		xs = append(xs, db.InsertAgent(roagent.O{
+			Position:       a.p,
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         a.r,
+			Mass:           1,
+			Size:           size.FSmall,
+		}).ID())
+	}
+
+	type config struct {
+		name   string
+		k      int
+		filter func(a roagent.RO) bool
+		want   []id.ID
+	}
+
+	configs := []config{
+		{
+			name:   "Zero",
+			k:      0,
+			filter: func(roagent.RO) bool { return true },
+			want:   nil,
+		},
+		{
+			// The agent at (0, 12) is closer than the agent at
+			// (10, 0) due to its larger radius.
+			name:   "Radius",
+			k:      2,
+			filter: func(roagent.RO) bool { return true },
+			want:   []id.ID{xs[2], xs[1]},
+		},
+		{
+			name:   "Filter",
+			k:      2,
+			filter: func(a roagent.RO) bool { return a.ID() != xs[2] },
+			want:   []id.ID{xs[1], xs[3]},
+		},
+		{
+			name:   "All",
+			k:      10,
+			filter: func(roagent.RO) bool { return true },
+			want:   []id.ID{xs[2], xs[1], xs[3], xs[0], xs[4]},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var got []id.ID
+			for _, a := range db.NearestAgents(vector.V{0, 0}, c.k, c.filter) {
+				got = append(got, a.ID())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("NearestAgents() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNearestFeatures(t *testing.T) {
+	db := New(DefaultO)
+
+	var xs []id.ID
+	for _, r := range []hyperrectangle.R{
+		*hyperrectangle.New(vector.V{10, 10}, vector.V{20, 20}),
+		*hyperrectangle.New(vector.V{-1, -1}, vector.V{1, 1}),
+		*hyperrectangle.New(vector.V{-9, 0}, vector.V{-8, 1}),
+	} {
+		xs = append(xs, db.InsertFeature(rofeature.O{AABB: r}).ID())
+	}
+
+	var got []id.ID
+	for _, f := range db.NearestFeatures(vector.V{0, 0}, 2, func(rofeature.RO) bool { return true }) {
+		got = append(got, f.ID())
+	}
+	if want := []id.ID{xs[1], xs[2]}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("NearestFeatures() = %v, want = %v", got, want)
+	}
+}
+
+func TestNearestAgentsConformance(t *testing.T) {
+	db := New(DefaultO)
+
+	// Snap agents to a coarse grid to generate distance ties.
+	for i := 0; i < 1000; i++ {
+		db.InsertAgent(roagent.O{
+			Position:       vector.V{float64(rand.Intn(100)), float64(rand.Intn(100))},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         float64(1 + rand.Intn(2)),
+			Mass:           1,
+			Size:           size.FSmall,
+		})
+	}
+
+	for i := 0; i < 100; i++ {
+		p := vector.V{float64(rand.Intn(120) - 10), float64(rand.Intn(120) - 10)}
+		k := rand.Intn(20)
+		filter := func(a roagent.RO) bool { return a.ID()%3 == 0 }
+
+		var want []roagent.RO
+		db.ForEachAgent(func(a roagent.RO) bool {
+			if filter(a) {
+				want = append(want, a)
+			}
+			return true
+		})
+		sort.Slice(want, func(i, j int) bool {
+			di, dj := DistanceAgent(want[i], p), DistanceAgent(want[j], p)
+			if di == dj {
+				return want[i].ID() < want[j].ID()
+			}
+			return di < dj
+		})
+		if len(want) > k {
+			want = want[:k]
+		}
+
+		got := db.NearestAgents(p, k, filter)
+		if len(got) == 0 && len(want) == 0 {
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("NearestAgents(%v, %v) = %v, want = %v", p, k, got, want)
+		}
+	}
+}
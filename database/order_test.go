@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+)
+
+func TestOrdered(t *testing.T) {
+	o := DefaultO
+	o.Ordered = true
+	db := New(o)
+
+	for i := 0; i < 128; i++ {
+		x := db.InsertAgent(roagent.O{
+			Position:       vector.V{float64(i % 16), float64(i / 16)},
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		}).ID()
+		if i%3 == 0 {
+			db.DeleteAgent(x)
+		}
+	}
+
+	var got []id.ID
+	db.ForEachAgent(func(a roagent.RO) bool {
+		got = append(got, a.ID())
+		return true
+	})
+	for i := 1; i < len(got); i++ {
+		if got[i-1] >= got[i] {
+			t.Fatalf("ForEachAgent() = %v, want sorted IDs", got)
+		}
+	}
+
+	got = got[:0]
+	for _, a := range db.QueryAgents(*hyperrectangle.New(
+		vector.V{0, 0},
+		vector.V{16, 16},
+	), func(roagent.RO) bool { return true }) {
+		got = append(got, a.ID())
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i-1] >= got[i] {
+			t.Fatalf("QueryAgents() = %v, want sorted IDs", got)
+		}
+	}
+}
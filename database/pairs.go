@@ -0,0 +1,329 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/tree"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+)
+
+// AgentPair is an unordered pair of agents with overlapping AABBs. The agent A
+// always has the smaller ID.
+type AgentPair struct {
+	A roagent.RO
+	B roagent.RO
+}
+
+type AgentFeaturePair struct {
+	Agent   roagent.RO
+	Feature rofeature.RO
+}
+
+type ProjectileAgentPair struct {
+	Projectile roprojectile.RO
+	Agent      roagent.RO
+}
+
+// AgentPairs returns all unique pairs of agents whose AABBs overlap and which
+// pass the input filter, e.g. filters.AgentIsColliding. The filter is called
+// exactly once per unordered pair, with the agent of the smaller ID as the
+// first argument. Pairs are sorted by the IDs of the first and then second
+// agent.
+//
+// Pairs are found by a traversal of the agent BVH against itself, which only
+// visits pairs of overlapping BVH nodes.
+//
+// AgentPairs is a read-only operation and may be called concurrently with other
+// read-only operations.
+func (db *DB) AgentPairs(filter func(a roagent.RO, b roagent.RO) bool) []AgentPair {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.AgentPairs(filter)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	return agentPairs(db.agents.Index(), func(x id.ID) roagent.RO { return db.agents.At(x) }, filter)
+}
+
+// AgentFeaturePairs returns all pairs of agents and features whose AABBs
+// overlap and which pass the input filter, e.g.
+// filters.AgentIsCollidingWithFeature. Pairs are sorted by the agent and then
+// feature IDs.
+//
+// AgentFeaturePairs is a read-only operation and may be called concurrently
+// with other read-only operations.
+func (db *DB) AgentFeaturePairs(filter func(a roagent.RO, f rofeature.RO) bool) []AgentFeaturePair {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.AgentFeaturePairs(filter)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	return agentFeaturePairs(
+		db.agents.Index(), func(x id.ID) roagent.RO { return db.agents.At(x) },
+		db.features.Index(), func(x id.ID) rofeature.RO { return db.features.At(x) },
+		filter,
+	)
+}
+
+// ProjectileAgentPairs returns all pairs of projectiles and agents whose AABBs
+// overlap and which pass the input filter. Pairs are sorted by the projectile
+// and then agent IDs.
+//
+// ProjectileAgentPairs is a read-only operation and may be called concurrently
+// with other read-only operations.
+func (db *DB) ProjectileAgentPairs(filter func(p roprojectile.RO, a roagent.RO) bool) []ProjectileAgentPair {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.ProjectileAgentPairs(filter)
+	}
+
+	// Locks are always acquired in agent, feature, projectile order to
+	// avoid deadlocks.
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	return projectileAgentPairs(
+		db.projectiles.Index(), func(x id.ID) roprojectile.RO { return db.projectiles.At(x) },
+		db.agents.Index(), func(x id.ID) roagent.RO { return db.agents.At(x) },
+		filter,
+	)
+}
+
+func agentPairs(c container.C, at func(x id.ID) roagent.RO, filter func(a roagent.RO, b roagent.RO) bool) []AgentPair {
+	var pairs []AgentPair
+	overlaps(c, func(x id.ID) hyperrectangle.R { return at(x).AABB() }, nil, nil, func(x, y id.ID) {
+		if y < x {
+			x, y = y, x
+		}
+		if a, b := at(x), at(y); filter(a, b) {
+			pairs = append(pairs, AgentPair{A: a, B: b})
+		}
+	})
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].A.ID() == pairs[j].A.ID() {
+			return pairs[i].B.ID() < pairs[j].B.ID()
+		}
+		return pairs[i].A.ID() < pairs[j].A.ID()
+	})
+	return pairs
+}
+
+func agentFeaturePairs(agents container.C, agentAt func(x id.ID) roagent.RO, features container.C, featureAt func(x id.ID) rofeature.RO, filter func(a roagent.RO, f rofeature.RO) bool) []AgentFeaturePair {
+	var pairs []AgentFeaturePair
+	overlaps(
+		agents, func(x id.ID) hyperrectangle.R { return agentAt(x).AABB() },
+		features, func(y id.ID) hyperrectangle.R { return featureAt(y).AABB() },
+		func(x, y id.ID) {
+			if a, f := agentAt(x), featureAt(y); filter(a, f) {
+				pairs = append(pairs, AgentFeaturePair{Agent: a, Feature: f})
+			}
+		},
+	)
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Agent.ID() == pairs[j].Agent.ID() {
+			return pairs[i].Feature.ID() < pairs[j].Feature.ID()
+		}
+		return pairs[i].Agent.ID() < pairs[j].Agent.ID()
+	})
+	return pairs
+}
+
+func projectileAgentPairs(projectiles container.C, projectileAt func(x id.ID) roprojectile.RO, agents container.C, agentAt func(x id.ID) roagent.RO, filter func(p roprojectile.RO, a roagent.RO) bool) []ProjectileAgentPair {
+	var pairs []ProjectileAgentPair
+	overlaps(
+		projectiles, func(x id.ID) hyperrectangle.R { return projectileAt(x).AABB() },
+		agents, func(y id.ID) hyperrectangle.R { return agentAt(y).AABB() },
+		func(x, y id.ID) {
+			if p, a := projectileAt(x), agentAt(y); filter(p, a) {
+				pairs = append(pairs, ProjectileAgentPair{Projectile: p, Agent: a})
+			}
+		},
+	)
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Projectile.ID() == pairs[j].Projectile.ID() {
+			return pairs[i].Agent.ID() < pairs[j].Agent.ID()
+		}
+		return pairs[i].Projectile.ID() < pairs[j].Projectile.ID()
+	})
+	return pairs
+}
+
+// overlaps calls fn once for each pair of objects (x, y) such that x is in the
+// spatial index c, y is in d, and the AABBs r(x) and s(y) overlap, where
+// touching AABBs are considered overlapping. If d is nil, c is instead paired
+// with itself, and fn is called once for each unordered pair x != y.
+//
+// If both indexes expose their node hierarchy, overlaps traverses both trees
+// at once, and only descends into pairs of overlapping nodes. Otherwise, all
+// AABBs are swept.
+func overlaps(c container.C, r func(x id.ID) hyperrectangle.R, d container.C, s func(y id.ID) hyperrectangle.R, fn func(x, y id.ID)) {
+	self := d == nil
+	if self {
+		d, s = c, r
+	}
+
+	if h, ok := c.(hierarchy); ok {
+		if k, ok := d.(hierarchy); ok {
+			o := overlapper{r: r, s: s, fn: fn}
+			if m, n := h.Root(), k.Root(); m != nil && n != nil {
+				if self {
+					o.self(m)
+				} else {
+					o.cross(m, n)
+				}
+			}
+			return
+		}
+	}
+
+	xs := c.IDs()
+	rs := make([]hyperrectangle.R, 0, len(xs))
+	for _, x := range xs {
+		rs = append(rs, r(x))
+	}
+	if self {
+		sweep(rs, nil, func(i, j int) { fn(xs[i], xs[j]) })
+		return
+	}
+
+	ys := d.IDs()
+	ss := make([]hyperrectangle.R, 0, len(ys))
+	for _, y := range ys {
+		ss = append(ss, s(y))
+	}
+	sweep(rs, ss, func(i, j int) { fn(xs[i], ys[j]) })
+}
+
+// overlapper is a simultaneous traversal of two BVHs. Node AABBs bound the
+// AABBs of all objects under the node, and so a pair of disjoint nodes cannot
+// contain an overlapping pair of objects.
+type overlapper struct {
+	r  func(x id.ID) hyperrectangle.R
+	s  func(y id.ID) hyperrectangle.R
+	fn func(x, y id.ID)
+}
+
+// self finds all overlapping pairs of objects under the input node.
+func (o overlapper) self(n *tree.N) {
+	if n.IsLeaf() {
+		xs := n.IDs()
+		for i, x := range xs {
+			for _, y := range xs[i+1:] {
+				if !hyperrectangle.Disjoint(o.r(x), o.r(y)) {
+					o.fn(x, y)
+				}
+			}
+		}
+		return
+	}
+	o.self(n.Left())
+	o.self(n.Right())
+	o.cross(n.Left(), n.Right())
+}
+
+// cross finds all overlapping pairs of objects (x, y), where x is under m and y
+// is under n.
+func (o overlapper) cross(m *tree.N, n *tree.N) {
+	if hyperrectangle.Disjoint(m.AABB(), n.AABB()) {
+		return
+	}
+	switch {
+	case m.IsLeaf() && n.IsLeaf():
+		for _, x := range m.IDs() {
+			for _, y := range n.IDs() {
+				if !hyperrectangle.Disjoint(o.r(x), o.s(y)) {
+					o.fn(x, y)
+				}
+			}
+		}
+	// Descend into the larger node first, which prunes more pairs.
+	case n.IsLeaf() || (!m.IsLeaf() && hyperrectangle.V(m.AABB()) >= hyperrectangle.V(n.AABB())):
+		o.cross(m.Left(), n)
+		o.cross(m.Right(), n)
+	default:
+		o.cross(m, n.Left())
+		o.cross(m, n.Right())
+	}
+}
+
+// interval is an AABB in a sweep, tagged with its source list and index.
+type interval struct {
+	set  int
+	i    int
+	aabb hyperrectangle.R
+}
+
+// sweep calls fn once for each pair of indices (i, j) such that rs[i] and
+// ss[j] overlap, where touching AABBs are considered overlapping. If ss is
+// nil, rs is instead paired with itself, and fn is called once for each
+// unordered pair i != j.
+//
+// sweep sorts all AABBs once along the X-axis, and then only checks the
+// Y-axis of AABBs whose X-intervals overlap, i.e. sweep-and-prune.
+func sweep(rs []hyperrectangle.R, ss []hyperrectangle.R, fn func(i, j int)) {
+	self := ss == nil
+
+	es := make([]interval, 0, len(rs)+len(ss))
+	for i, r := range rs {
+		es = append(es, interval{set: 0, i: i, aabb: r})
+	}
+	for j, s := range ss {
+		es = append(es, interval{set: 1, i: j, aabb: s})
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].aabb.Min().X() < es[j].aabb.Min().X() })
+
+	// active tracks the AABBs of each list whose X-interval may still
+	// overlap with subsequent AABBs in the sweep.
+	var active [2][]interval
+	for _, e := range es {
+		other := 1 - e.set
+		if self {
+			other = e.set
+		}
+
+		emin, emax := e.aabb.Min(), e.aabb.Max()
+
+		// Prune the active list in place. As AABBs are swept in order
+		// of their lower X bound, any AABB which ends before the
+		// current one starts cannot overlap any subsequent AABB.
+		live := active[other][:0]
+		for _, f := range active[other] {
+			fmin, fmax := f.aabb.Min(), f.aabb.Max()
+			if fmax.X() < emin.X() {
+				continue
+			}
+			live = append(live, f)
+
+			if emin.Y() <= fmax.Y() && fmin.Y() <= emax.Y() {
+				if e.set == 0 && !self {
+					fn(e.i, f.i)
+				} else {
+					fn(f.i, e.i)
+				}
+			}
+		}
+		active[other] = live
+		active[e.set] = append(active[e.set], e)
+	}
+}
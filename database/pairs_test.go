@@ -0,0 +1,292 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/downflux/go-bvh/bvh"
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/tree"
+	"github.com/downflux/go-database/filters"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+func TestPairs(t *testing.T) {
+	db := New(DefaultO)
+
+	var xs []id.ID
+	for _, p := range []vector.V{{0, 0}, {1.5, 0}, {3, 0}, {100, 100}, {1.5, 1.5}} {
+		xs = append(xs, db.InsertAgent(roagent.O{
+			Position:       p,
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		}).ID())
+	}
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{3.5, -1}, vector.V{5, 1}),
+	}).ID()
+	p := db.InsertProjectile(roprojectile.O{
+		Position:       vector.V{100, 100.5},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+	}).ID()
+
+	t.Run("Agents", func(t *testing.T) {
+		var got [][2]id.ID
+		for _, pair := range db.AgentPairs(filters.AgentIsColliding) {
+			got = append(got, [2]id.ID{pair.A.ID(), pair.B.ID()})
+		}
+		// The agents at (0, 0) and (3, 0) overlap the AABB but not the
+		// circle of the agent at (1.5, 1.5).
+		want := [][2]id.ID{
+			{xs[0], xs[1]},
+			{xs[1], xs[2]},
+			{xs[1], xs[4]},
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("AgentPairs() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("AgentFeatures", func(t *testing.T) {
+		var got [][2]id.ID
+		for _, pair := range db.AgentFeaturePairs(filters.AgentIsCollidingWithFeature) {
+			got = append(got, [2]id.ID{pair.Agent.ID(), pair.Feature.ID()})
+		}
+		if want := [][2]id.ID{{xs[2], f}}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("AgentFeaturePairs() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("ProjectileAgents", func(t *testing.T) {
+		var got [][2]id.ID
+		for _, pair := range db.ProjectileAgentPairs(func(roprojectile.RO, roagent.RO) bool { return true }) {
+			got = append(got, [2]id.ID{pair.Projectile.ID(), pair.Agent.ID()})
+		}
+		if want := [][2]id.ID{{p, xs[3]}}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("ProjectileAgentPairs() = %v, want = %v", got, want)
+		}
+	})
+}
+
+func TestSweep(t *testing.T) {
+	type config struct {
+		name string
+		rs   []hyperrectangle.R
+		ss   []hyperrectangle.R
+	}
+
+	random := func(n int) []hyperrectangle.R {
+		rs := make([]hyperrectangle.R, 0, n)
+		for i := 0; i < n; i++ {
+			// Snap to a coarse grid to generate touching AABBs.
+			x, y := float64(rand.Intn(20)), float64(rand.Intn(20))
+			rs = append(rs, *hyperrectangle.New(
+				vector.V{x, y},
+				vector.V{x + float64(rand.Intn(4)), y + float64(rand.Intn(4))},
+			))
+		}
+		return rs
+	}
+
+	configs := []config{
+		{name: "Self/Empty", rs: nil, ss: nil},
+		{name: "Self", rs: random(200), ss: nil},
+		{name: "Cross/Empty", rs: random(10), ss: []hyperrectangle.R{}},
+		{name: "Cross", rs: random(200), ss: random(100)},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var want [][2]int
+			if c.ss == nil {
+				for i := range c.rs {
+					for j := i + 1; j < len(c.rs); j++ {
+						if !hnd.Disjoint(hnd.R(c.rs[i]), hnd.R(c.rs[j])) {
+							want = append(want, [2]int{i, j})
+						}
+					}
+				}
+			} else {
+				for i := range c.rs {
+					for j := range c.ss {
+						if !hnd.Disjoint(hnd.R(c.rs[i]), hnd.R(c.ss[j])) {
+							want = append(want, [2]int{i, j})
+						}
+					}
+				}
+			}
+
+			var got [][2]int
+			sweep(c.rs, c.ss, func(i, j int) {
+				if c.ss == nil && j < i {
+					i, j = j, i
+				}
+				got = append(got, [2]int{i, j})
+			})
+			sort.Slice(got, func(i, j int) bool {
+				if got[i][0] == got[j][0] {
+					return got[i][1] < got[j][1]
+				}
+				return got[i][0] < got[j][0]
+			})
+
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("sweep() = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
+// indexes are the spatial indexes over which overlaps is tested. The tree.T
+// index is traversed directly, while bvh.T falls back to a sweep.
+var indexes = []struct {
+	name string
+	new  func() container.C
+}{
+	{
+		name: "tree",
+		new: func() container.C {
+			t, err := tree.New(tree.O{LeafSize: 4, Tolerance: 1.15})
+			if err != nil {
+				panic(err)
+			}
+			return t
+		},
+	},
+	{
+		name: "bvh",
+		new:  func() container.C { return bvh.New(bvh.O{K: 2, LeafSize: 4, Tolerance: 1.15}) },
+	},
+}
+
+// index inserts the input AABBs into a new spatial index, keyed by position.
+func index(f func() container.C, rs []hyperrectangle.R) container.C {
+	c := f()
+	for i, r := range rs {
+		if err := c.Insert(id.ID(i), hnd.R(r)); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+func TestOverlaps(t *testing.T) {
+	type config struct {
+		name string
+		rs   []hyperrectangle.R
+		ss   []hyperrectangle.R
+	}
+
+	random := func(n int) []hyperrectangle.R {
+		rs := make([]hyperrectangle.R, 0, n)
+		for i := 0; i < n; i++ {
+			x, y := float64(rand.Intn(50)), float64(rand.Intn(50))
+			rs = append(rs, *hyperrectangle.New(
+				vector.V{x, y},
+				vector.V{x + float64(rand.Intn(4)), y + float64(rand.Intn(4))},
+			))
+		}
+		return rs
+	}
+
+	var configs []config
+	for _, n := range []int{0, 1, 500} {
+		configs = append(configs, config{name: fmt.Sprintf("Self/N=%v", n), rs: random(n)})
+		configs = append(configs, config{name: fmt.Sprintf("Cross/N=%v", n), rs: random(n), ss: random(n / 2)})
+	}
+
+	for _, c := range configs {
+		var want [][2]id.ID
+		if c.ss == nil {
+			for i := range c.rs {
+				for j := i + 1; j < len(c.rs); j++ {
+					if !hnd.Disjoint(hnd.R(c.rs[i]), hnd.R(c.rs[j])) {
+						want = append(want, [2]id.ID{id.ID(i), id.ID(j)})
+					}
+				}
+			}
+		} else {
+			for i := range c.rs {
+				for j := range c.ss {
+					if !hnd.Disjoint(hnd.R(c.rs[i]), hnd.R(c.ss[j])) {
+						want = append(want, [2]id.ID{id.ID(i), id.ID(j)})
+					}
+				}
+			}
+		}
+
+		for _, x := range indexes {
+			t.Run(fmt.Sprintf("%v/%v", c.name, x.name), func(t *testing.T) {
+				r := func(x id.ID) hyperrectangle.R { return c.rs[x] }
+				s := func(y id.ID) hyperrectangle.R { return c.ss[y] }
+
+				var got [][2]id.ID
+				fn := func(i, j id.ID) {
+					if c.ss == nil && j < i {
+						i, j = j, i
+					}
+					got = append(got, [2]id.ID{i, j})
+				}
+				if c.ss == nil {
+					overlaps(index(x.new, c.rs), r, nil, nil, fn)
+				} else {
+					overlaps(index(x.new, c.rs), r, index(x.new, c.ss), s, fn)
+				}
+				sort.Slice(got, func(i, j int) bool {
+					if got[i][0] == got[j][0] {
+						return got[i][1] < got[j][1]
+					}
+					return got[i][0] < got[j][0]
+				})
+
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("overlaps() = %v, want = %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkOverlaps(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		// Agents are spread out over a square map at a constant density.
+		l := math.Sqrt(float64(n)) * 4
+		rs := make([]hyperrectangle.R, 0, n)
+		for i := 0; i < n; i++ {
+			x, y := rand.Float64()*l, rand.Float64()*l
+			rs = append(rs, *hyperrectangle.New(vector.V{x, y}, vector.V{x + 2, y + 2}))
+		}
+		r := func(x id.ID) hyperrectangle.R { return rs[x] }
+
+		for _, x := range indexes {
+			c := index(x.new, rs)
+			b.Run(fmt.Sprintf("%v/N=%v", x.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					overlaps(c, r, nil, nil, func(x, y id.ID) {})
+				}
+			})
+		}
+	}
+}
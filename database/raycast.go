@@ -0,0 +1,170 @@
+package database
+
+import (
+	"math"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/hypersphere"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+	dhs "github.com/downflux/go-database/geometry/hypersphere"
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// AgentHit describes the first intersection of a ray with an agent.
+type AgentHit struct {
+	Agent roagent.RO
+
+	// P is the point of intersection.
+	P vector.V
+
+	// D is the distance from the origin of the ray to P.
+	D float64
+
+	// N is the unit surface normal of the agent at P. If the ray
+	// originates inside the agent, N faces against the ray direction.
+	N vector.V
+}
+
+// FeatureHit describes the first intersection of a ray with a feature.
+type FeatureHit struct {
+	Feature rofeature.RO
+
+	P vector.V
+	D float64
+	N vector.V
+}
+
+// querier is implemented by BVHs which support a custom traversal filter, e.g.
+// tree.T or bvh.T.
+type querier interface {
+	Query(f func(r hnd.R) bool) []id.ID
+}
+
+// RaycastAgents finds the first agent which passes the input filter and whose
+// circle is hit by the segment starting at p in the direction d, up to a
+// distance of max. A ray of infinite length may be cast by setting max to
+// +Inf. Ties are broken by agent ID.
+//
+// RaycastAgents is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) RaycastAgents(p vector.V, d vector.V, max float64, filter func(a roagent.RO) bool) (AgentHit, bool) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.RaycastAgents(p, d, max, filter)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+
+	return raycastAgents(db.agents.Index(), func(x id.ID) roagent.RO { return db.agents.At(x) }, p, d, max, filter)
+}
+
+// RaycastFeatures finds the first feature which passes the input filter and
+// whose AABB is hit by the segment starting at p in the direction d, up to a
+// distance of max.
+//
+// See RaycastAgents for more information.
+func (db *DB) RaycastFeatures(p vector.V, d vector.V, max float64, filter func(f rofeature.RO) bool) (FeatureHit, bool) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.RaycastFeatures(p, d, max, filter)
+	}
+
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+
+	return raycastFeatures(db.features.Index(), func(x id.ID) rofeature.RO { return db.features.At(x) }, p, d, max, filter)
+}
+
+// raycastAgents finds the first agent in the input spatial index which is hit
+// by the segment.
+//
+// See RaycastAgents for more information.
+func raycastAgents(c container.C, at func(x id.ID) roagent.RO, p vector.V, d vector.V, max float64, filter func(a roagent.RO) bool) (AgentHit, bool) {
+	if vector.SquaredMagnitude(d) == 0 {
+		return AgentHit{}, false
+	}
+	u := vector.Unit(d)
+
+	var hit AgentHit
+	var ok bool
+	for _, x := range raycast(c, p, u, max) {
+		a := at(x)
+		t, collide := dhs.IntersectRay(*hypersphere.New(a.Position(), a.Radius()), p, u)
+		if !collide || t > max || (ok && (t > hit.D || (t == hit.D && x > hit.Agent.ID()))) {
+			continue
+		}
+		if !filter(a) {
+			continue
+		}
+
+		q := vector.Add(p, vector.Scale(t, u))
+		n := vector.Scale(-1, u)
+		if t > 0 {
+			n = vector.Unit(vector.Sub(q, a.Position()))
+		}
+		hit, ok = AgentHit{Agent: a, P: q, D: t, N: n}, true
+	}
+	return hit, ok
+}
+
+// raycastFeatures finds the first feature in the input spatial index which is
+// hit by the segment.
+//
+// See RaycastAgents for more information.
+func raycastFeatures(c container.C, at func(x id.ID) rofeature.RO, p vector.V, d vector.V, max float64, filter func(f rofeature.RO) bool) (FeatureHit, bool) {
+	if vector.SquaredMagnitude(d) == 0 {
+		return FeatureHit{}, false
+	}
+	u := vector.Unit(d)
+
+	var hit FeatureHit
+	var ok bool
+	for _, x := range raycast(c, p, u, max) {
+		f := at(x)
+		t, n, collide := dhr.IntersectRay(f.AABB(), p, u)
+		if !collide || t > max || (ok && (t > hit.D || (t == hit.D && x > hit.Feature.ID()))) {
+			continue
+		}
+		if !filter(f) {
+			continue
+		}
+		hit, ok = FeatureHit{Feature: f, P: vector.Add(p, vector.Scale(t, u)), D: t, N: n}, true
+	}
+	return hit, ok
+}
+
+// raycast returns the IDs of all objects in the BVH whose AABB is hit by the
+// segment p + t * d, 0 <= t <= max. Here d is a unit vector.
+func raycast(c container.C, p vector.V, d vector.V, max float64) []id.ID {
+	if q, ok := c.(querier); ok {
+		return q.Query(func(r hnd.R) bool {
+			t, _, ok := dhr.IntersectRay(hyperrectangle.R(r), p, d)
+			return ok && t <= max
+		})
+	}
+
+	// Fall back to checking the AABB of the segment. Note that we need to
+	// guard against 0 * ∞ for axis-aligned rays.
+	q := vector.M{0, 0}
+	for i := range q {
+		q[i] = p[i]
+		if d[i] != 0 {
+			q[i] += d[i] * max
+		}
+	}
+	return c.BroadPhase(hnd.R(*hyperrectangle.New(
+		vector.V{math.Min(p.X(), q.X()), math.Min(p.Y(), q.Y())},
+		vector.V{math.Max(p.X(), q.X()), math.Max(p.Y(), q.Y())},
+	)))
+}
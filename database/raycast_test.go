@@ -0,0 +1,108 @@
+package database
+
+import (
+	"math"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+)
+
+func TestRaycastAgents(t *testing.T) {
+	db := New(DefaultO)
+
+	var xs []id.ID
+	for _, p := range []vector.V{{10, 0}, {5, 0}, {0, 5}} {
+		xs = append(xs, db.InsertAgent(roagent.O{
+			Position:       p,
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           size.FSmall,
+		}).ID())
+	}
+
+	type config struct {
+		name   string
+		p      vector.V
+		d      vector.V
+		max    float64
+		filter func(a roagent.RO) bool
+		ok     bool
+		want   AgentHit
+	}
+
+	configs := []config{
+		{
+			name:   "Ray",
+			p:      vector.V{0, 0},
+			d:      vector.V{2, 0},
+			max:    math.Inf(1),
+			filter: func(roagent.RO) bool { return true },
+			ok:     true,
+			want:   AgentHit{Agent: db.GetAgentOrDie(xs[1]), P: vector.V{4, 0}, D: 4, N: vector.V{-1, 0}},
+		},
+		{
+			name:   "Ray/Filter",
+			p:      vector.V{0, 0},
+			d:      vector.V{1, 0},
+			max:    math.Inf(1),
+			filter: func(a roagent.RO) bool { return a.ID() != xs[1] },
+			ok:     true,
+			want:   AgentHit{Agent: db.GetAgentOrDie(xs[0]), P: vector.V{9, 0}, D: 9, N: vector.V{-1, 0}},
+		},
+		{
+			name:   "Segment/Short",
+			p:      vector.V{0, 0},
+			d:      vector.V{1, 0},
+			max:    3,
+			filter: func(roagent.RO) bool { return true },
+			ok:     false,
+		},
+		{
+			name:   "Ray/Miss",
+			p:      vector.V{0, 0},
+			d:      vector.V{-1, -1},
+			max:    math.Inf(1),
+			filter: func(roagent.RO) bool { return true },
+			ok:     false,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := db.RaycastAgents(c.p, c.d, c.max, c.filter)
+			if ok != c.ok {
+				t.Fatalf("RaycastAgents() = _, %v, want = _, %v", ok, c.ok)
+			}
+			if ok && (got.Agent.ID() != c.want.Agent.ID() || !vector.Within(got.P, c.want.P) || !epsilon.Within(got.D, c.want.D) || !vector.Within(got.N, c.want.N)) {
+				t.Errorf("RaycastAgents() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRaycastFeatures(t *testing.T) {
+	db := New(DefaultO)
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{5, -10}, vector.V{6, 10}),
+	})
+
+	got, ok := db.RaycastFeatures(vector.V{0, 0}, vector.V{1, 1}, math.Inf(1), func(rofeature.RO) bool { return true })
+	if !ok {
+		t.Fatalf("RaycastFeatures() = _, %v, want = _, %v", ok, true)
+	}
+	if want := (FeatureHit{Feature: f, P: vector.V{5, 5}, D: 5 * math.Sqrt(2), N: vector.V{-1, 0}}); got.Feature.ID() != want.Feature.ID() || !vector.Within(got.P, want.P) || !epsilon.Within(got.D, want.D) || !vector.Within(got.N, want.N) {
+		t.Errorf("RaycastFeatures() = %v, want = %v", got, want)
+	}
+}
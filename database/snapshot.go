@@ -0,0 +1,699 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-database/internal/agent"
+	"github.com/downflux/go-database/internal/feature"
+	"github.com/downflux/go-database/internal/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+	roprojectile "github.com/downflux/go-database/projectile"
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// Snapshot is a read-only, point-in-time view of the built-in entities of a
+// DB, e.g. for rendering or AI threads to read the state of the previous tick
+// while the next tick mutates the live DB.
+//
+// Snapshots are copy-on-write: creating a snapshot does not copy the DB.
+// Instead, the live DB preserves a copy of each entity in all open snapshots
+// before the entity is first mutated or deleted, and the snapshot lazily
+// copies any entity it reads which has not been mutated since. Readers of a
+// snapshot therefore never observe the live entities. Entities inserted after
+// the snapshot was taken are not visible.
+//
+// Snapshot follows the same concurrency rules as the DB, i.e. may be read
+// concurrently with mutations on the live DB if the DB was created with
+// O.Concurrent set. Snapshots must be released via Release once they are no
+// longer needed, as the live DB otherwise continues preserving entities into
+// the snapshot.
+type Snapshot struct {
+	db *DB
+
+	// counter is the value of the DB counter at the time of the
+	// snapshot. Only entities with smaller counter bits are visible.
+	counter uint64
+
+	// hidden is the set of IDs which were allocated before the snapshot
+	// was taken, but whose entities were inserted afterwards, e.g. by a
+	// Tx. hidden is only mutated while holding exclusive access to the
+	// live DB, and may be read while holding any live entity lock.
+	hidden map[id.ID]bool
+
+	// l guards the frozen entities below. l must be acquired after the
+	// corresponding live entity lock and guard.
+	l           rw
+	agents      *frozen[*agent.A]
+	features    *frozen[*feature.F]
+	projectiles *frozen[*projectile.P]
+}
+
+var _ RO = &Snapshot{}
+
+// Snapshot returns a point-in-time view of the DB.
+//
+// Snapshot acquires exclusive access to the DB, and may be called
+// concurrently with other DB operations.
+func (db *DB) Snapshot() *Snapshot {
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	db.featuresL.Lock()
+	defer db.featuresL.Unlock()
+
+	db.projectilesL.Lock()
+	defer db.projectilesL.Unlock()
+
+	s := &Snapshot{
+		db:          db,
+		counter:     db.count(),
+		hidden:      map[id.ID]bool{},
+		l:           newRW(db.o.Concurrent),
+		agents:      newFrozen[*agent.A](db.o),
+		features:    newFrozen[*feature.F](db.o),
+		projectiles: newFrozen[*projectile.P](db.o),
+	}
+
+	db.snapshotsL.Lock()
+	defer db.snapshotsL.Unlock()
+
+	db.snapshots = append(db.snapshots, s)
+	return s
+}
+
+// Release detaches the snapshot from the live DB. The snapshot must not be
+// used after it has been released.
+func (s *Snapshot) Release() {
+	db := s.db
+
+	db.snapshotsL.Lock()
+	defer db.snapshotsL.Unlock()
+
+	for i, t := range db.snapshots {
+		if t == s {
+			db.snapshots = append(db.snapshots[:i], db.snapshots[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Snapshot) GetAgent(x id.ID) (roagent.RO, error) {
+	if a, ok, done := lookup(s, s.agents, x); done {
+		if !ok {
+			return nil, fmt.Errorf("cannot find agent %v: %w", x, errors.ErrNotFound)
+		}
+		return a, nil
+	}
+
+	s.db.agentsL.RLock()
+	defer s.db.agentsL.RUnlock()
+
+	a, err := freeze(s, s.agents, s.db.agents, x)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *Snapshot) GetFeature(x id.ID) (rofeature.RO, error) {
+	if f, ok, done := lookup(s, s.features, x); done {
+		if !ok {
+			return nil, fmt.Errorf("cannot find feature %v: %w", x, errors.ErrNotFound)
+		}
+		return f, nil
+	}
+
+	s.db.featuresL.RLock()
+	defer s.db.featuresL.RUnlock()
+
+	f, err := freeze(s, s.features, s.db.features, x)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *Snapshot) GetProjectile(x id.ID) (roprojectile.RO, error) {
+	if p, ok, done := lookup(s, s.projectiles, x); done {
+		if !ok {
+			return nil, fmt.Errorf("cannot find projectile %v: %w", x, errors.ErrNotFound)
+		}
+		return p, nil
+	}
+
+	s.db.projectilesL.RLock()
+	defer s.db.projectilesL.RUnlock()
+
+	p, err := freeze(s, s.projectiles, s.db.projectiles, x)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Snapshot) GetAgentOrDie(x id.ID) roagent.RO {
+	a, err := s.GetAgent(x)
+	if err != nil {
+		panic(err.Error())
+	}
+	return a
+}
+
+func (s *Snapshot) GetFeatureOrDie(x id.ID) rofeature.RO {
+	f, err := s.GetFeature(x)
+	if err != nil {
+		panic(err.Error())
+	}
+	return f
+}
+
+func (s *Snapshot) GetProjectileOrDie(x id.ID) roprojectile.RO {
+	p, err := s.GetProjectile(x)
+	if err != nil {
+		panic(err.Error())
+	}
+	return p
+}
+
+func (s *Snapshot) ListAgents() <-chan roagent.RO {
+	return s.ListAgentsContext(context.Background())
+}
+
+func (s *Snapshot) ListFeatures() <-chan rofeature.RO {
+	return s.ListFeaturesContext(context.Background())
+}
+
+func (s *Snapshot) ListProjectiles() <-chan roprojectile.RO {
+	return s.ListProjectilesContext(context.Background())
+}
+
+// ListAgentsContext returns all agents in the snapshot, and stops early if the
+// input context is cancelled. As with DB.ListAgentsContext, the agents are
+// copied into a list before the first is sent.
+func (s *Snapshot) ListAgentsContext(ctx context.Context) <-chan roagent.RO {
+	var agents []roagent.RO
+	s.ForEachAgent(func(a roagent.RO) bool {
+		agents = append(agents, a)
+		return true
+	})
+	return table.Stream(ctx, agents)
+}
+
+// ListFeaturesContext returns all features in the snapshot, and stops early if
+// the input context is cancelled.
+//
+// See ListAgentsContext for more information.
+func (s *Snapshot) ListFeaturesContext(ctx context.Context) <-chan rofeature.RO {
+	var features []rofeature.RO
+	s.ForEachFeature(func(f rofeature.RO) bool {
+		features = append(features, f)
+		return true
+	})
+	return table.Stream(ctx, features)
+}
+
+// ListProjectilesContext returns all projectiles in the snapshot, and stops
+// early if the input context is cancelled.
+//
+// See ListAgentsContext for more information.
+func (s *Snapshot) ListProjectilesContext(ctx context.Context) <-chan roprojectile.RO {
+	var projectiles []roprojectile.RO
+	s.ForEachProjectile(func(p roprojectile.RO) bool {
+		projectiles = append(projectiles, p)
+		return true
+	})
+	return table.Stream(ctx, projectiles)
+}
+
+// ForEachAgent calls fn on each agent in the snapshot, and stops iterating
+// early if fn returns false.
+//
+// Unlike DB.ForEachAgent, the live DB is not locked while fn is called. The
+// first iteration copies all agents which have not yet been copied into the
+// snapshot, and allocates the list of copies, which is reused by all later
+// iterations.
+func (s *Snapshot) ForEachAgent(fn func(a roagent.RO) bool) {
+	s.db.agentsL.RLock()
+	agents := freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	for _, a := range agents {
+		if !fn(a) {
+			return
+		}
+	}
+}
+
+func (s *Snapshot) ForEachFeature(fn func(f rofeature.RO) bool) {
+	s.db.featuresL.RLock()
+	features := freezeAll(s, s.features, s.db.features)
+	s.db.featuresL.RUnlock()
+
+	for _, f := range features {
+		if !fn(f) {
+			return
+		}
+	}
+}
+
+func (s *Snapshot) ForEachProjectile(fn func(p roprojectile.RO) bool) {
+	s.db.projectilesL.RLock()
+	projectiles := freezeAll(s, s.projectiles, s.db.projectiles)
+	s.db.projectilesL.RUnlock()
+
+	for _, p := range projectiles {
+		if !fn(p) {
+			return
+		}
+	}
+}
+
+func (s *Snapshot) QueryAgents(q hyperrectangle.R, filter func(a roagent.RO) bool) []roagent.RO {
+	s.db.agentsL.RLock()
+	candidates := query(s, s.agents, s.db.agents, q)
+	s.db.agentsL.RUnlock()
+
+	results := make([]roagent.RO, 0, len(candidates))
+	for _, a := range candidates {
+		if filter(a) {
+			results = append(results, a)
+		}
+	}
+	return results
+}
+
+func (s *Snapshot) QueryFeatures(q hyperrectangle.R, filter func(f rofeature.RO) bool) []rofeature.RO {
+	s.db.featuresL.RLock()
+	candidates := query(s, s.features, s.db.features, q)
+	s.db.featuresL.RUnlock()
+
+	results := make([]rofeature.RO, 0, len(candidates))
+	for _, f := range candidates {
+		if filter(f) {
+			results = append(results, f)
+		}
+	}
+	return results
+}
+
+func (s *Snapshot) QueryProjectiles(q hyperrectangle.R, filter func(p roprojectile.RO) bool) []roprojectile.RO {
+	s.db.projectilesL.RLock()
+	candidates := query(s, s.projectiles, s.db.projectiles, q)
+	s.db.projectilesL.RUnlock()
+
+	results := make([]roprojectile.RO, 0, len(candidates))
+	for _, p := range candidates {
+		if filter(p) {
+			results = append(results, p)
+		}
+	}
+	return results
+}
+
+// QueryAgentsInRadius returns all agents whose circle overlaps the circle of
+// radius r centered at p.
+func (s *Snapshot) QueryAgentsInRadius(p vector.V, r float64, filter func(a roagent.RO) bool) []roagent.RO {
+	return s.QueryAgents(bound(p, r), func(a roagent.RO) bool {
+		d := r + a.Radius()
+		if vector.SquaredMagnitude(vector.Sub(a.Position(), p)) > d*d {
+			return false
+		}
+		return filter(a)
+	})
+}
+
+// QueryFeaturesInRadius returns all features whose AABB overlaps the circle of
+// radius r centered at p.
+func (s *Snapshot) QueryFeaturesInRadius(p vector.V, r float64, filter func(f rofeature.RO) bool) []rofeature.RO {
+	return s.QueryFeatures(bound(p, r), func(f rofeature.RO) bool {
+		if !dhr.IntersectCircle(f.AABB(), p, r) {
+			return false
+		}
+		return filter(f)
+	})
+}
+
+// NearestAgents returns up to k agents which pass the input filter, sorted by
+// the distance from p to the agent's circle.
+//
+// See DB.NearestAgents for more information.
+func (s *Snapshot) NearestAgents(p vector.V, k int, filter func(a roagent.RO) bool) []roagent.RO {
+	s.db.agentsL.RLock()
+	freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	return nearest(s.agents.index, func(x id.ID) roagent.RO { return s.agents.data[x] }, p, k, DistanceAgent, filter)
+}
+
+// NearestFeatures returns up to k features which pass the input filter, sorted
+// by the distance from p to the feature's AABB.
+//
+// See DB.NearestFeatures for more information.
+func (s *Snapshot) NearestFeatures(p vector.V, k int, filter func(f rofeature.RO) bool) []rofeature.RO {
+	s.db.featuresL.RLock()
+	freezeAll(s, s.features, s.db.features)
+	s.db.featuresL.RUnlock()
+
+	return nearest(s.features.index, func(x id.ID) rofeature.RO { return s.features.data[x] }, p, k, DistanceFeature, filter)
+}
+
+// RaycastAgents finds the first agent which passes the input filter and whose
+// circle is hit by the segment starting at p in the direction d.
+//
+// See DB.RaycastAgents for more information.
+func (s *Snapshot) RaycastAgents(p vector.V, d vector.V, max float64, filter func(a roagent.RO) bool) (AgentHit, bool) {
+	s.db.agentsL.RLock()
+	freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	return raycastAgents(s.agents.index, func(x id.ID) roagent.RO { return s.agents.data[x] }, p, d, max, filter)
+}
+
+// RaycastFeatures finds the first feature which passes the input filter and
+// whose AABB is hit by the segment starting at p in the direction d.
+//
+// See DB.RaycastFeatures for more information.
+func (s *Snapshot) RaycastFeatures(p vector.V, d vector.V, max float64, filter func(f rofeature.RO) bool) (FeatureHit, bool) {
+	s.db.featuresL.RLock()
+	freezeAll(s, s.features, s.db.features)
+	s.db.featuresL.RUnlock()
+
+	return raycastFeatures(s.features.index, func(x id.ID) rofeature.RO { return s.features.data[x] }, p, d, max, filter)
+}
+
+// AgentPairs returns all unique pairs of agents whose AABBs overlap and which
+// pass the input filter.
+//
+// See DB.AgentPairs for more information.
+func (s *Snapshot) AgentPairs(filter func(a roagent.RO, b roagent.RO) bool) []AgentPair {
+	s.db.agentsL.RLock()
+	freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	return agentPairs(s.agents.index, func(x id.ID) roagent.RO { return s.agents.data[x] }, filter)
+}
+
+// AgentFeaturePairs returns all pairs of agents and features whose AABBs
+// overlap and which pass the input filter.
+//
+// See DB.AgentFeaturePairs for more information.
+func (s *Snapshot) AgentFeaturePairs(filter func(a roagent.RO, f rofeature.RO) bool) []AgentFeaturePair {
+	s.db.agentsL.RLock()
+	freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	s.db.featuresL.RLock()
+	freezeAll(s, s.features, s.db.features)
+	s.db.featuresL.RUnlock()
+
+	return agentFeaturePairs(
+		s.agents.index, func(x id.ID) roagent.RO { return s.agents.data[x] },
+		s.features.index, func(x id.ID) rofeature.RO { return s.features.data[x] },
+		filter,
+	)
+}
+
+// ProjectileAgentPairs returns all pairs of projectiles and agents whose AABBs
+// overlap and which pass the input filter.
+//
+// See DB.ProjectileAgentPairs for more information.
+func (s *Snapshot) ProjectileAgentPairs(filter func(p roprojectile.RO, a roagent.RO) bool) []ProjectileAgentPair {
+	s.db.agentsL.RLock()
+	freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	s.db.projectilesL.RLock()
+	freezeAll(s, s.projectiles, s.db.projectiles)
+	s.db.projectilesL.RUnlock()
+
+	return projectileAgentPairs(
+		s.projectiles.index, func(x id.ID) roprojectile.RO { return s.projectiles.data[x] },
+		s.agents.index, func(x id.ID) roagent.RO { return s.agents.data[x] },
+		filter,
+	)
+}
+
+// SweepProjectile finds the earliest contact of the input projectile with any
+// agent or feature which passes the respective filter.
+//
+// See DB.SweepProjectile for more information.
+func (s *Snapshot) SweepProjectile(x id.ID, dt float64, agentFilter func(p roprojectile.RO, a roagent.RO) bool, featureFilter func(p roprojectile.RO, f rofeature.RO) bool) (Impact, bool, error) {
+	p, err := s.GetProjectile(x)
+	if err != nil {
+		return Impact{}, false, err
+	}
+
+	s.db.agentsL.RLock()
+	freezeAll(s, s.agents, s.db.agents)
+	s.db.agentsL.RUnlock()
+
+	s.db.featuresL.RLock()
+	freezeAll(s, s.features, s.db.features)
+	s.db.featuresL.RUnlock()
+
+	impact, ok := sweepProjectile(
+		p, dt,
+		s.agents.index, func(x id.ID) roagent.RO { return s.agents.data[x] },
+		s.features.index, func(x id.ID) rofeature.RO { return s.features.data[x] },
+		agentFilter, featureFilter,
+	)
+	return impact, ok, nil
+}
+
+// visible checks if the entity was inserted before the snapshot was taken.
+// The caller must hold a live entity lock.
+func (s *Snapshot) visible(x id.ID) bool { return count(x) < s.counter && !s.hidden[x] }
+
+// preserveAgent copies the agent into all open snapshots before it is
+// mutated. The caller must hold the agents lock and the agent guard, or the
+// agents write lock.
+func (db *DB) preserveAgent(a *agent.A) {
+	db.snapshotsL.RLock()
+	defer db.snapshotsL.RUnlock()
+
+	for _, s := range db.snapshots {
+		if s.visible(a.ID()) {
+			preserve(s, s.agents, a)
+		}
+	}
+}
+
+// preserveFeature copies the feature into all open snapshots before it is
+// mutated.
+//
+// See preserveAgent for more information.
+func (db *DB) preserveFeature(f *feature.F) {
+	db.snapshotsL.RLock()
+	defer db.snapshotsL.RUnlock()
+
+	for _, s := range db.snapshots {
+		if s.visible(f.ID()) {
+			preserve(s, s.features, f)
+		}
+	}
+}
+
+// preserveProjectile copies the projectile into all open snapshots before it
+// is mutated.
+//
+// See preserveAgent for more information.
+func (db *DB) preserveProjectile(p *projectile.P) {
+	db.snapshotsL.RLock()
+	defer db.snapshotsL.RUnlock()
+
+	for _, s := range db.snapshots {
+		if s.visible(p.ID()) {
+			preserve(s, s.projectiles, p)
+		}
+	}
+}
+
+// hide marks the newly inserted entity as invisible to all open snapshots
+// which were taken after its ID was allocated. The caller must have exclusive
+// access to the live DB.
+func (db *DB) hide(x id.ID) {
+	db.snapshotsL.RLock()
+	defer db.snapshotsL.RUnlock()
+
+	for _, s := range db.snapshots {
+		if s.visible(x) {
+			s.hidden[x] = true
+		}
+	}
+}
+
+// cloner is implemented by the internal entity types.
+type cloner[V any] interface {
+	table.E
+	Clone() V
+}
+
+// frozen is the set of entities of a single kind which have been copied into
+// a snapshot, along with a BVH over their (immutable) AABBs.
+type frozen[V cloner[V]] struct {
+	data  map[id.ID]V
+	index container.C
+
+	// complete indicates all visible entities have been copied, i.e. the
+	// live DB no longer needs to be consulted. Once complete is set, data
+	// and index are never mutated again, and may be read without holding
+	// the snapshot lock.
+	complete bool
+
+	// list caches the result of freezeAll once complete is set.
+	list []V
+}
+
+func newFrozen[V cloner[V]](o O) *frozen[V] {
+	return &frozen[V]{
+		data:  make(map[id.ID]V, 64),
+		index: o.bvh(),
+	}
+}
+
+// preserve copies the input live entity into the snapshot if it is visible
+// and has not yet been copied. The caller must hold the live entity lock and
+// the entity guard (or exclusive access to the live entity).
+func preserve[V cloner[V]](s *Snapshot, f *frozen[V], v V) V {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	x := v.ID()
+	if u, ok := f.data[x]; ok {
+		return u
+	}
+
+	u := v.Clone()
+	f.data[x] = u
+	if err := f.index.Insert(x, hnd.R(u.AABB())); err != nil {
+		panic(fmt.Sprintf("cannot preserve entity %v: %v", x, err))
+	}
+	return u
+}
+
+// lookup returns the snapshot copy of the entity with the input ID. If done
+// is set, the live DB does not need to be consulted, and ok indicates if the
+// entity exists in the snapshot.
+func lookup[V cloner[V]](s *Snapshot, f *frozen[V], x id.ID) (v V, ok bool, done bool) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	v, ok = f.data[x]
+	return v, ok, ok || f.complete
+}
+
+// freeze returns the snapshot copy of the entity with the input ID. The caller
+// must hold the live entity read lock.
+func freeze[V cloner[V]](s *Snapshot, f *frozen[V], t *table.T[V], x id.ID) (V, error) {
+	s.l.Lock()
+	v, ok := f.data[x]
+	s.l.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	var zero V
+	if !s.visible(x) {
+		return zero, fmt.Errorf("cannot find %v %v: %w", t.Name(), x, errors.ErrNotFound)
+	}
+	v, err := t.Get(x)
+	if err != nil {
+		return zero, err
+	}
+
+	s.db.guards.Lock(x)
+	defer s.db.guards.Unlock(x)
+
+	return preserve(s, f, v), nil
+}
+
+// freezeAll copies all visible entities into the snapshot, and returns the
+// snapshot copies. The caller must hold the live entity read lock, and must not
+// modify the returned slice.
+//
+// The returned slice is cached, i.e. only the first call allocates.
+func freezeAll[V cloner[V]](s *Snapshot, f *frozen[V], t *table.T[V]) []V {
+	s.l.Lock()
+	complete, vs := f.complete, f.list
+	s.l.Unlock()
+	if complete {
+		return vs
+	}
+
+	t.ForEach(func(v V) bool {
+		if s.visible(v.ID()) {
+			freeze(s, f, t, v.ID())
+		}
+		return true
+	})
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	// Another reader may have completed the snapshot concurrently.
+	if f.complete {
+		return f.list
+	}
+
+	xs := make([]id.ID, 0, len(f.data))
+	for x := range f.data {
+		xs = append(xs, x)
+	}
+	if s.db.o.Ordered {
+		table.SortIDs(xs)
+	}
+
+	vs = make([]V, 0, len(xs))
+	for _, x := range xs {
+		vs = append(vs, f.data[x])
+	}
+
+	f.complete = true
+	f.list = vs
+	return vs
+}
+
+// query returns the snapshot copies of all entities whose AABB (at the time
+// of the snapshot) overlaps the query rectangle. The caller must hold the
+// live entity read lock.
+func query[V cloner[V]](s *Snapshot, f *frozen[V], t *table.T[V], q hyperrectangle.R) []V {
+	// The live BVH is accurate for all entities which have not been
+	// mutated since the snapshot was taken, and all mutated entities have
+	// been copied into the snapshot BVH. We therefore ensure all live
+	// candidates are copied, after which the snapshot BVH is sufficient.
+	s.l.Lock()
+	complete := f.complete
+	s.l.Unlock()
+
+	if !complete {
+		for _, x := range t.BroadPhase(q) {
+			if s.visible(x) {
+				freeze(s, f, t, x)
+			}
+		}
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	candidates := f.index.BroadPhase(hnd.R(q))
+	if s.db.o.Ordered {
+		table.SortIDs(candidates)
+	}
+
+	vs := make([]V, 0, len(candidates))
+	for _, x := range candidates {
+		vs = append(vs, f.data[x])
+	}
+	return vs
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+)
+
+func newSnapshotAgent(p vector.V) roagent.O {
+	return roagent.O{
+		Position:       p,
+		TargetPosition: p,
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		Size:           size.FSmall,
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	type config struct {
+		name string
+		// mutate modifies the live DB after the snapshot is taken,
+		// given the IDs of two agents at (0, 0) and (10, 0) and a
+		// feature spanning (20, 20) to (30, 30). mutate returns the ID
+		// of any newly inserted entity.
+		mutate func(db *DB, a, b, f id.ID) id.ID
+	}
+
+	configs := []config{
+		{
+			name:   "Noop",
+			mutate: func(db *DB, a, b, f id.ID) id.ID { return 0 },
+		},
+		{
+			name: "SetAgentPosition",
+			mutate: func(db *DB, a, b, f id.ID) id.ID {
+				db.SetAgentPosition(a, vector.V{100, 100})
+				db.SetAgentVelocity(b, vector.V{1, 1})
+				return 0
+			},
+		},
+		{
+			name: "DeleteAgent",
+			mutate: func(db *DB, a, b, f id.ID) id.ID {
+				db.DeleteAgent(a)
+				return 0
+			},
+		},
+		{
+			name: "InsertAgent",
+			mutate: func(db *DB, a, b, f id.ID) id.ID {
+				return db.InsertAgent(newSnapshotAgent(vector.V{1, 1})).ID()
+			},
+		},
+		{
+			name: "SetFeatureAABB",
+			mutate: func(db *DB, a, b, f id.ID) id.ID {
+				db.SetFeatureAABB(f, *hyperrectangle.New(vector.V{40, 40}, vector.V{50, 50}))
+				return 0
+			},
+		},
+		{
+			name: "Tx",
+			mutate: func(db *DB, a, b, f id.ID) id.ID {
+				tx := db.Begin()
+				tx.SetAgentPosition(a, vector.V{100, 100})
+				tx.DeleteAgent(b)
+				tx.DeleteFeature(f)
+				y := tx.InsertAgent(newSnapshotAgent(vector.V{1, 1}))
+				if err := tx.Commit(); err != nil {
+					panic(err)
+				}
+				return y
+			},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := New(DefaultO)
+			a := db.InsertAgent(newSnapshotAgent(vector.V{0, 0})).ID()
+			b := db.InsertAgent(newSnapshotAgent(vector.V{10, 0})).ID()
+			f := db.InsertFeature(rofeature.O{
+				AABB: *hyperrectangle.New(vector.V{20, 20}, vector.V{30, 30}),
+			}).ID()
+
+			s := db.Snapshot()
+			defer s.Release()
+
+			if y := c.mutate(db, a, b, f); y != 0 {
+				if _, err := s.GetAgent(y); !errors.Is(err, dberrors.ErrNotFound) {
+					t.Errorf("GetAgent() = %v, want = %v", err, dberrors.ErrNotFound)
+				}
+			}
+
+			for x, want := range map[id.ID]vector.V{
+				a: vector.V{0, 0},
+				b: vector.V{10, 0},
+			} {
+				got, err := s.GetAgent(x)
+				if err != nil {
+					t.Fatalf("GetAgent() = %v, want = %v", err, nil)
+				}
+				if !vector.Within(got.Position(), want) {
+					t.Errorf("Position() = %v, want = %v", got.Position(), want)
+				}
+				if !vector.Within(got.Velocity(), vector.V{0, 0}) {
+					t.Errorf("Velocity() = %v, want = %v", got.Velocity(), vector.V{0, 0})
+				}
+			}
+
+			var got []id.ID
+			s.ForEachAgent(func(a roagent.RO) bool {
+				got = append(got, a.ID())
+				return true
+			})
+			table.SortIDs(got)
+			if want := []id.ID{a, b}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("ForEachAgent() = %v, want = %v", got, want)
+			}
+
+			got = nil
+			for _, a := range s.QueryAgents(*hyperrectangle.New(vector.V{-1, -1}, vector.V{2, 2}), func(roagent.RO) bool { return true }) {
+				got = append(got, a.ID())
+			}
+			if want := []id.ID{a}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("QueryAgents() = %v, want = %v", got, want)
+			}
+
+			got = nil
+			for _, f := range s.QueryFeatures(*hyperrectangle.New(vector.V{25, 25}, vector.V{26, 26}), func(rofeature.RO) bool { return true }) {
+				got = append(got, f.ID())
+			}
+			if want := []id.ID{f}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("QueryFeatures() = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRelease(t *testing.T) {
+	db := New(DefaultO)
+	x := db.InsertAgent(newSnapshotAgent(vector.V{0, 0})).ID()
+
+	s := db.Snapshot()
+	s.Release()
+
+	if got := len(db.snapshots); got != 0 {
+		t.Errorf("len(snapshots) = %v, want = %v", got, 0)
+	}
+
+	db.SetAgentPosition(x, vector.V{10, 10})
+	if got := len(s.agents.data); got != 0 {
+		t.Errorf("len(data) = %v, want = %v", got, 0)
+	}
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	o := DefaultO
+	o.Concurrent = true
+
+	db := New(o)
+
+	const n = 100
+	var xs []id.ID
+	for i := 0; i < n; i++ {
+		xs = append(xs, db.InsertAgent(newSnapshotAgent(vector.V{float64(3 * i), 0})).ID())
+	}
+
+	s := db.Snapshot()
+	defer s.Release()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i, x := range xs {
+			db.SetAgentPosition(x, vector.V{float64(3 * i), 100})
+			db.SetAgentVelocity(x, vector.V{1, 1})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i, x := range xs {
+			a := s.GetAgentOrDie(x)
+			if want := (vector.V{float64(3 * i), 0}); !vector.Within(a.Position(), want) {
+				t.Errorf("Position() = %v, want = %v", a.Position(), want)
+			}
+			s.QueryAgentsInRadius(vector.V{0, 0}, 10, func(roagent.RO) bool { return true })
+		}
+	}()
+	wg.Wait()
+
+	if got := len(s.QueryAgents(*hyperrectangle.New(vector.V{-1, -1}, vector.V{float64(3 * n), 1}), func(roagent.RO) bool { return true })); got != n {
+		t.Errorf("len(QueryAgents()) = %v, want = %v", got, n)
+	}
+}
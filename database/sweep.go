@@ -0,0 +1,126 @@
+package database
+
+import (
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/database/table"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/hypersphere"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+	dhs "github.com/downflux/go-database/geometry/hypersphere"
+	roprojectile "github.com/downflux/go-database/projectile"
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// Impact describes the earliest contact of a moving projectile with an agent
+// or a feature. Exactly one of Agent and Feature is set.
+type Impact struct {
+	Agent   roagent.RO
+	Feature rofeature.RO
+
+	// T is the time of impact, measured from the start of the sweep.
+	T float64
+
+	// P is the position of the projectile at the time of impact.
+	P vector.V
+
+	// N is the unit surface normal of the agent or feature at the contact
+	// point. If the projectile already overlaps the entity at the start
+	// of the sweep, N faces against the projectile velocity.
+	N vector.V
+}
+
+// SweepProjectile finds the earliest contact of the input projectile with any
+// agent or feature which passes the respective filter, as the projectile
+// travels at its current velocity for a timestep of dt. Unlike checking the
+// projectile AABB at the start and end of the timestep, fast projectiles will
+// not tunnel through thin features or small agents. Ties in the time of impact
+// are broken in favor of agents, then by ID.
+//
+// A stationary projectile does not sweep any area and will never report an
+// impact; use QueryAgents and QueryFeatures to check for overlaps instead.
+//
+// SweepProjectile is a read-only operation and may be called concurrently with
+// other read-only operations.
+func (db *DB) SweepProjectile(x id.ID, dt float64, agentFilter func(p roprojectile.RO, a roagent.RO) bool, featureFilter func(p roprojectile.RO, f rofeature.RO) bool) (Impact, bool, error) {
+	if db.o.DoubleBuffered {
+		db.frontL.RLock()
+		defer db.frontL.RUnlock()
+
+		return db.front.SweepProjectile(x, dt, agentFilter, featureFilter)
+	}
+
+	db.agentsL.RLock()
+	defer db.agentsL.RUnlock()
+	db.featuresL.RLock()
+	defer db.featuresL.RUnlock()
+	db.projectilesL.RLock()
+	defer db.projectilesL.RUnlock()
+
+	p, err := db.projectiles.Get(x)
+	if err != nil {
+		return Impact{}, false, err
+	}
+
+	impact, ok := sweepProjectile(
+		p, dt,
+		db.agents.Index(), func(x id.ID) roagent.RO { return db.agents.At(x) },
+		db.features.Index(), func(x id.ID) rofeature.RO { return db.features.At(x) },
+		agentFilter, featureFilter,
+	)
+	return impact, ok, nil
+}
+
+// sweepProjectile finds the earliest contact of the input projectile with the
+// agents and features in the input spatial indexes.
+//
+// See SweepProjectile for more information.
+func sweepProjectile(p roprojectile.RO, dt float64, agents container.C, agentAt func(x id.ID) roagent.RO, features container.C, featureAt func(x id.ID) rofeature.RO, agentFilter func(p roprojectile.RO, a roagent.RO) bool, featureFilter func(p roprojectile.RO, f rofeature.RO) bool) (Impact, bool) {
+	v := p.Velocity()
+	r := p.Radius()
+
+	if vector.SquaredMagnitude(v) == 0 {
+		return Impact{}, false
+	}
+
+	// The broad phase AABB is the union of the projectile AABB at the
+	// start and end of the timestep.
+	q := hnd.R(hyperrectangle.Union(p.AABB(), bound(vector.Add(p.Position(), vector.Scale(dt, v)), r)))
+
+	var impact Impact
+	var ok bool
+
+	candidates := agents.BroadPhase(q)
+	table.SortIDs(candidates)
+	for _, y := range candidates {
+		a := agentAt(y)
+		t, hit := dhs.IntersectRay(*hypersphere.New(a.Position(), a.Radius()+r), p.Position(), v)
+		if !hit || t > dt || (ok && t >= impact.T) || !agentFilter(p, a) {
+			continue
+		}
+
+		c := vector.Add(p.Position(), vector.Scale(t, v))
+		n := vector.Scale(-1, vector.Unit(v))
+		if t > 0 {
+			n = vector.Unit(vector.Sub(c, a.Position()))
+		}
+		impact, ok = Impact{Agent: a, T: t, P: c, N: n}, true
+	}
+
+	candidates = features.BroadPhase(q)
+	table.SortIDs(candidates)
+	for _, y := range candidates {
+		f := featureAt(y)
+		t, n, hit := dhr.SweepCircle(f.AABB(), p.Position(), v, r)
+		if !hit || t > dt || (ok && t >= impact.T) || !featureFilter(p, f) {
+			continue
+		}
+		impact, ok = Impact{Feature: f, T: t, P: vector.Add(p.Position(), vector.Scale(t, v)), N: n}, true
+	}
+
+	return impact, ok
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+)
+
+func TestSweepProjectile(t *testing.T) {
+	db := New(DefaultO)
+
+	// A thin wall and a small agent, both of which a projectile moving at
+	// 100 units per tick would tunnel through if only checked at the
+	// start and end of the tick.
+	f := db.InsertFeature(rofeature.O{
+		AABB: *hyperrectangle.New(vector.V{50, -10}, vector.V{50.1, 10}),
+	})
+	a := db.InsertAgent(roagent.O{
+		Position:       vector.V{30, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         0.5,
+		Mass:           1,
+		Size:           size.FSmall,
+	})
+	p := db.InsertProjectile(roprojectile.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{100, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         0.5,
+	})
+
+	all := func(roprojectile.RO, roagent.RO) bool { return true }
+	none := func(roprojectile.RO, roagent.RO) bool { return false }
+	walls := func(roprojectile.RO, rofeature.RO) bool { return true }
+
+	t.Run("Agent", func(t *testing.T) {
+		got, ok, err := db.SweepProjectile(p.ID(), 1, all, walls)
+		if err != nil || !ok {
+			t.Fatalf("SweepProjectile() = _, %v, %v, want = _, true, nil", ok, err)
+		}
+		if got.Agent == nil || got.Agent.ID() != a.ID() || !epsilon.Within(got.T, 0.29) || !vector.Within(got.N, vector.V{-1, 0}) {
+			t.Errorf("SweepProjectile() = %v, want agent %v at T = 0.29", got, a.ID())
+		}
+	})
+
+	t.Run("Feature", func(t *testing.T) {
+		got, ok, err := db.SweepProjectile(p.ID(), 1, none, walls)
+		if err != nil || !ok {
+			t.Fatalf("SweepProjectile() = _, %v, %v, want = _, true, nil", ok, err)
+		}
+		if got.Feature == nil || got.Feature.ID() != f.ID() || !epsilon.Within(got.T, 0.495) || !vector.Within(got.N, vector.V{-1, 0}) {
+			t.Errorf("SweepProjectile() = %v, want feature %v at T = 0.495", got, f.ID())
+		}
+	})
+
+	t.Run("Short", func(t *testing.T) {
+		if _, ok, err := db.SweepProjectile(p.ID(), 0.1, all, walls); err != nil || ok {
+			t.Errorf("SweepProjectile() = _, %v, %v, want = _, false, nil", ok, err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, _, err := db.SweepProjectile(100, 1, all, walls); !errors.Is(err, dberrors.ErrNotFound) {
+			t.Errorf("SweepProjectile() = _, _, %v, want = _, _, %v", err, dberrors.ErrNotFound)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package table
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+)
+
+// insertID adds the input ID into the sorted list. As IDs are allocated
+// monotonically, this is typically an append.
+func insertID(xs []id.ID, x id.ID) []id.ID {
+	if n := len(xs); n == 0 || xs[n-1] < x {
+		return append(xs, x)
+	}
+
+	i := sort.Search(len(xs), func(i int) bool { return xs[i] >= x })
+	if i < len(xs) && xs[i] == x {
+		return xs
+	}
+	xs = append(xs, 0)
+	copy(xs[i+1:], xs[i:])
+	xs[i] = x
+	return xs
+}
+
+// removeID removes the input ID from the sorted list.
+func removeID(xs []id.ID, x id.ID) []id.ID {
+	i := sort.Search(len(xs), func(i int) bool { return xs[i] >= x })
+	if i == len(xs) || xs[i] != x {
+		return xs
+	}
+	return append(xs[:i], xs[i+1:]...)
+}
+
+// SortIDs sorts the input IDs in ascending order.
+func SortIDs(xs []id.ID) { sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] }) }
@@ -0,0 +1,57 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+)
+
+func TestInsertID(t *testing.T) {
+	type config struct {
+		name string
+		xs   []id.ID
+		x    id.ID
+		want []id.ID
+	}
+
+	configs := []config{
+		{name: "Empty", xs: nil, x: 1, want: []id.ID{1}},
+		{name: "Append", xs: []id.ID{1, 2}, x: 3, want: []id.ID{1, 2, 3}},
+		{name: "Prepend", xs: []id.ID{1, 2}, x: 0, want: []id.ID{0, 1, 2}},
+		{name: "Middle", xs: []id.ID{1, 3}, x: 2, want: []id.ID{1, 2, 3}},
+		{name: "Duplicate", xs: []id.ID{1, 2, 3}, x: 2, want: []id.ID{1, 2, 3}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := insertID(c.xs, c.x); fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("insertID() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRemoveID(t *testing.T) {
+	type config struct {
+		name string
+		xs   []id.ID
+		x    id.ID
+		want []id.ID
+	}
+
+	configs := []config{
+		{name: "Empty", xs: nil, x: 1, want: nil},
+		{name: "Missing", xs: []id.ID{1, 3}, x: 2, want: []id.ID{1, 3}},
+		{name: "Head", xs: []id.ID{1, 2, 3}, x: 1, want: []id.ID{2, 3}},
+		{name: "Tail", xs: []id.ID{1, 2, 3}, x: 3, want: []id.ID{1, 2}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := removeID(c.xs, c.x); fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("removeID() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Package table provides a generic entity table, i.e. an ID-keyed map of
+// entities with an optional spatial index, on top of which the entity
+// collections of the database are built.
+//
+// Users may create additional tables for custom entity kinds, e.g. pickups or
+// regions, either standalone or attached to a database.DB via
+// database.RegisterTable.
+package table
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// E is the interface entities stored in a table must implement.
+type E interface {
+	ID() id.ID
+	AABB() hyperrectangle.R
+}
+
+type O struct {
+	// Name is the human-readable name of the entity kind, e.g. "agent",
+	// and is used in error messages.
+	Name string
+
+	// Index is the spatial index over the entity AABBs, e.g. a tree.T. If
+	// unset, spatial queries fall back to a linear scan over all entities.
+	Index container.C
+
+	// Ordered indicates the table will maintain a sorted list of IDs, and
+	// iterate over and return entities in ID order.
+	Ordered bool
+}
+
+// T is an entity table.
+//
+// T is not safe for concurrent use; callers are responsible for guarding
+// mutations, e.g. with a sync.RWMutex.
+type T[V E] struct {
+	name    string
+	data    map[id.ID]V
+	index   container.C
+	ordered bool
+	order   []id.ID
+}
+
+func New[V E](o O) *T[V] {
+	return &T[V]{
+		name:    o.Name,
+		data:    make(map[id.ID]V, 1024),
+		index:   o.Index,
+		ordered: o.Ordered,
+	}
+}
+
+func (t *T[V]) Name() string { return t.name }
+func (t *T[V]) Len() int     { return len(t.data) }
+
+// Index returns the underlying spatial index, which may be nil. Callers must
+// not mutate the returned index.
+func (t *T[V]) Index() container.C { return t.index }
+
+// Get returns the entity with the input ID.
+func (t *T[V]) Get(x id.ID) (V, error) {
+	v, ok := t.data[x]
+	if !ok {
+		return v, fmt.Errorf("cannot find %v %v: %w", t.name, x, errors.ErrNotFound)
+	}
+	return v, nil
+}
+
+// At returns the entity with the input ID, or the zero value if the entity
+// does not exist. At is intended for IDs which are known to exist, e.g. IDs
+// returned by BroadPhase.
+func (t *T[V]) At(x id.ID) V { return t.data[x] }
+
+// Insert adds the entity into the table, keyed by its ID.
+//
+// The table is left unchanged if an error is returned.
+func (t *T[V]) Insert(v V) error {
+	x := v.ID()
+	if _, ok := t.data[x]; ok {
+		return fmt.Errorf("cannot insert %v %v: %w: duplicate ID", t.name, x, errors.ErrInvalidOptions)
+	}
+	if t.index != nil {
+		if err := t.index.Insert(x, hnd.R(v.AABB())); err != nil {
+			return fmt.Errorf("cannot insert %v %v: %w: %v", t.name, x, errors.ErrIndex, err)
+		}
+	}
+
+	t.data[x] = v
+	if t.ordered {
+		t.order = insertID(t.order, x)
+	}
+	return nil
+}
+
+// Delete removes the entity with the input ID from the table.
+func (t *T[V]) Delete(x id.ID) error {
+	if _, err := t.Get(x); err != nil {
+		return err
+	}
+	if t.index != nil {
+		if err := t.index.Remove(x); err != nil {
+			return fmt.Errorf("cannot delete %v %v: %w: %v", t.name, x, errors.ErrIndex, err)
+		}
+	}
+
+	delete(t.data, x)
+	if t.ordered {
+		t.order = removeID(t.order, x)
+	}
+	return nil
+}
+
+// Update refreshes the spatial index after the AABB of the entity with the
+// input ID has changed.
+func (t *T[V]) Update(x id.ID) error {
+	v, err := t.Get(x)
+	if err != nil {
+		return err
+	}
+	if t.index != nil {
+		if err := t.index.Update(x, hnd.R(v.AABB())); err != nil {
+			return fmt.Errorf("cannot update %v %v: %w: %v", t.name, x, errors.ErrIndex, err)
+		}
+	}
+	return nil
+}
+
+// IDs returns the IDs of all entities in the table in ascending order.
+func (t *T[V]) IDs() []id.ID {
+	if t.ordered {
+		return append(make([]id.ID, 0, len(t.order)), t.order...)
+	}
+
+	xs := make([]id.ID, 0, len(t.data))
+	for x := range t.data {
+		xs = append(xs, x)
+	}
+	SortIDs(xs)
+	return xs
+}
+
+// ForEach calls fn on each entity in the table, and stops iterating early if
+// fn returns false. ForEach does not allocate.
+func (t *T[V]) ForEach(fn func(v V) bool) {
+	if t.ordered {
+		for _, x := range t.order {
+			if !fn(t.data[x]) {
+				return
+			}
+		}
+		return
+	}
+
+	for _, v := range t.data {
+		if !fn(v) {
+			return
+		}
+	}
+}
+
+// List returns all entities in the table. The entities are collected
+// eagerly, so that the table may be mutated while the returned channel is
+// being drained.
+//
+// The returned channel is closed after all entities have been sent, or after
+// the input context is cancelled, whichever comes first.
+func (t *T[V]) List(ctx context.Context) <-chan V {
+	vs := make([]V, 0, len(t.data))
+	t.ForEach(func(v V) bool {
+		vs = append(vs, v)
+		return true
+	})
+	return Stream(ctx, vs)
+}
+
+// BroadPhase returns the IDs of all entities whose AABB overlaps the query
+// rectangle. The IDs are returned in ascending order if the table is ordered.
+func (t *T[V]) BroadPhase(q hyperrectangle.R) []id.ID {
+	var xs []id.ID
+	if t.index != nil {
+		xs = t.index.BroadPhase(hnd.R(q))
+	} else {
+		for x, v := range t.data {
+			if !hyperrectangle.Disjoint(q, v.AABB()) {
+				xs = append(xs, x)
+			}
+		}
+	}
+
+	if t.ordered {
+		SortIDs(xs)
+	}
+	return xs
+}
+
+// Query returns all entities whose AABB overlaps the query rectangle and
+// which pass the input filter.
+func (t *T[V]) Query(q hyperrectangle.R, filter func(v V) bool) []V {
+	candidates := t.BroadPhase(q)
+
+	results := make([]V, 0, len(candidates))
+	for _, x := range candidates {
+		if v := t.data[x]; filter(v) {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+// Stream sends the input values over the returned channel, which is closed
+// after all values have been sent, or after the input context is cancelled,
+// whichever comes first.
+func Stream[V any](ctx context.Context, vs []V) <-chan V {
+	ch := make(chan V, 256)
+	go func(ch chan<- V) {
+		defer close(ch)
+		for _, v := range vs {
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ch)
+	return ch
+}
@@ -0,0 +1,103 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/container/bruteforce"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	dberrors "github.com/downflux/go-database/errors"
+)
+
+type region struct {
+	id   id.ID
+	aabb hyperrectangle.R
+}
+
+func (r *region) ID() id.ID              { return r.id }
+func (r *region) AABB() hyperrectangle.R { return r.aabb }
+
+func TestT(t *testing.T) {
+	type config struct {
+		name    string
+		index   container.C
+		ordered bool
+	}
+
+	configs := []config{
+		{name: "Unindexed", index: nil, ordered: false},
+		{name: "Unindexed/Ordered", index: nil, ordered: true},
+		{name: "Indexed", index: bruteforce.New(), ordered: false},
+		{name: "Indexed/Ordered", index: bruteforce.New(), ordered: true},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			tbl := New[*region](O{
+				Name:    "region",
+				Index:   c.index,
+				Ordered: c.ordered,
+			})
+			for i := 0; i < 4; i++ {
+				tbl.Insert(&region{
+					id: id.ID(3 - i),
+					aabb: *hyperrectangle.New(
+						vector.V{float64(i), 0},
+						vector.V{float64(i) + 1, 1},
+					),
+				})
+			}
+
+			if err := tbl.Insert(&region{id: 0}); !errors.Is(err, dberrors.ErrInvalidOptions) {
+				t.Errorf("Insert() = %v, want = %v", err, dberrors.ErrInvalidOptions)
+			}
+			if err := tbl.Delete(100); !errors.Is(err, dberrors.ErrNotFound) {
+				t.Errorf("Delete() = %v, want = %v", err, dberrors.ErrNotFound)
+			}
+			if want := []id.ID{0, 1, 2, 3}; fmt.Sprint(tbl.IDs()) != fmt.Sprint(want) {
+				t.Errorf("IDs() = %v, want = %v", tbl.IDs(), want)
+			}
+
+			r, err := tbl.Get(3)
+			if err != nil {
+				t.Fatalf("Get() = %v, want = nil", err)
+			}
+			r.aabb = *hyperrectangle.New(vector.V{10, 10}, vector.V{11, 11})
+			if err := tbl.Update(3); err != nil {
+				t.Fatalf("Update() = %v, want = nil", err)
+			}
+			if err := tbl.Delete(1); err != nil {
+				t.Fatalf("Delete() = %v, want = nil", err)
+			}
+
+			var got []id.ID
+			for _, r := range tbl.Query(
+				*hyperrectangle.New(vector.V{0.5, 0}, vector.V{3.5, 1}),
+				func(*region) bool { return true },
+			) {
+				got = append(got, r.ID())
+			}
+			if c.ordered {
+				if want := []id.ID{0, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("Query() = %v, want = %v", got, want)
+				}
+			} else if len(got) != 2 {
+				t.Errorf("Query() = %v, want 2 regions", got)
+			}
+
+			got = got[:0]
+			for r := range tbl.List(context.Background()) {
+				got = append(got, r.ID())
+			}
+			if len(got) != tbl.Len() {
+				t.Errorf("List() = %v, want %v regions", got, tbl.Len())
+			}
+		})
+	}
+}
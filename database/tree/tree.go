@@ -0,0 +1,470 @@
+// Package tree implements a dynamic AABB tree, i.e. a BVH, which exposes its
+// node hierarchy to callers.
+//
+// The tree satisfies the container.C interface of github.com/downflux/go-bvh,
+// and may be used as the spatial index of a table.T. Unlike bvh.T, the nodes
+// of the tree may be traversed directly, which is necessary for e.g.
+// best-first nearest neighbor searches.
+package tree
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+var _ container.C = &T{}
+
+type O struct {
+	// LeafSize is the maximum number of objects in a leaf node.
+	LeafSize int
+
+	// Tolerance specifies the bounding buffer around each object as a
+	// factor of the area of its AABB, and must be at least one. Objects
+	// which move within their buffer do not need to be reinserted into
+	// the tree.
+	Tolerance float64
+}
+
+// T is a dynamic AABB tree over 2D objects.
+//
+// T is not safe for concurrent use.
+type T struct {
+	leafSize  int
+	tolerance float64
+
+	root *N
+
+	// leaves maps each object to the leaf node containing it.
+	leaves map[id.ID]*N
+
+	// data and buffers are the exact and buffered AABBs of each object.
+	data    map[id.ID]hyperrectangle.R
+	buffers map[id.ID]hyperrectangle.R
+}
+
+// N is a node of the tree. The AABB of an internal node bounds the AABBs of
+// its children, and the AABB of a leaf node bounds the buffered AABBs of its
+// objects.
+type N struct {
+	parent *N
+	left   *N
+	right  *N
+
+	// height is the length of the longest path to a leaf node, i.e. zero
+	// for leaf nodes.
+	height int
+	aabb   hyperrectangle.R
+
+	// ids is the set of objects in a leaf node.
+	ids []id.ID
+}
+
+// New returns an empty tree, or an error wrapping errors.ErrInvalidOptions if
+// the tolerance factor is smaller than one.
+func New(o O) (*T, error) {
+	if !(o.Tolerance >= 1) {
+		return nil, fmt.Errorf("cannot set tolerance factor %v: %w: tolerance must be at least 1", o.Tolerance, errors.ErrInvalidOptions)
+	}
+	if o.LeafSize < 1 {
+		o.LeafSize = 1
+	}
+	return &T{
+		leafSize:  o.LeafSize,
+		tolerance: o.Tolerance,
+		leaves:    make(map[id.ID]*N, 1024),
+		data:      make(map[id.ID]hyperrectangle.R, 1024),
+		buffers:   make(map[id.ID]hyperrectangle.R, 1024),
+	}, nil
+}
+
+// Root returns the root node of the tree, or nil if the tree is empty.
+func (t *T) Root() *N { return t.root }
+
+// AABB returns the exact AABB of the input object.
+func (t *T) AABB(x id.ID) (hyperrectangle.R, bool) {
+	r, ok := t.data[x]
+	return r, ok
+}
+
+func (n *N) AABB() hyperrectangle.R { return n.aabb }
+func (n *N) IsLeaf() bool           { return n.left == nil }
+func (n *N) Left() *N               { return n.left }
+func (n *N) Right() *N              { return n.right }
+
+// IDs returns the objects in a leaf node. The returned slice must not be
+// modified.
+func (n *N) IDs() []id.ID { return n.ids }
+
+func (t *T) IDs() []id.ID {
+	xs := make([]id.ID, 0, len(t.data))
+	for x := range t.data {
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+func (t *T) Insert(x id.ID, aabb hnd.R) error {
+	if _, ok := t.data[x]; ok {
+		return fmt.Errorf("cannot insert object %v: object already exists", x)
+	}
+
+	r := clone(hyperrectangle.R(aabb))
+	t.data[x] = r
+	t.buffers[x] = clone(r)
+	buffer(t.buffers[x], r, t.tolerance)
+
+	t.insert(x)
+	return nil
+}
+
+func (t *T) Remove(x id.ID) error {
+	if _, ok := t.data[x]; !ok {
+		return fmt.Errorf("cannot remove object %v: object not found", x)
+	}
+
+	t.remove(x)
+
+	delete(t.data, x)
+	delete(t.buffers, x)
+	return nil
+}
+
+// Update sets the AABB of the input object. The object is only reinserted
+// into the tree if the new AABB escapes the AABB of its leaf node.
+func (t *T) Update(x id.ID, aabb hnd.R) error {
+	if _, ok := t.data[x]; !ok {
+		return fmt.Errorf("cannot update object %v: object not found", x)
+	}
+
+	r := clone(hyperrectangle.R(aabb))
+	t.data[x] = r
+	if contains(t.buffers[x], r) {
+		return nil
+	}
+
+	// The buffer of an object which stays within its leaf node is clipped
+	// to the leaf, so that no node needs to be refit.
+	if n := t.leaves[x]; contains(n.aabb, r) {
+		buffer(t.buffers[x], r, t.tolerance)
+		intersect(t.buffers[x], n.aabb)
+		return nil
+	}
+
+	t.remove(x)
+	buffer(t.buffers[x], r, t.tolerance)
+	t.insert(x)
+	return nil
+}
+
+// BroadPhase finds all objects whose AABBs overlap the input AABB, where
+// touching AABBs are considered overlapping.
+func (t *T) BroadPhase(q hnd.R) []id.ID {
+	return t.Query(func(r hnd.R) bool { return !hnd.Disjoint(q, r) })
+}
+
+// Query finds all objects whose AABB passes the input filter. The filter is
+// applied recursively, i.e. the children of a node are only visited if the
+// AABB of the node also passes the filter.
+func (t *T) Query(f func(r hnd.R) bool) []id.ID {
+	xs := make([]id.ID, 0, 128)
+	if t.root == nil {
+		return xs
+	}
+
+	open := make([]*N, 0, 128)
+	open = append(open, t.root)
+
+	var n *N
+	for len(open) > 0 {
+		n, open = open[len(open)-1], open[:len(open)-1]
+		if !f(hnd.R(n.aabb)) {
+			continue
+		}
+		if n.IsLeaf() {
+			for _, x := range n.ids {
+				if f(hnd.R(t.data[x])) {
+					xs = append(xs, x)
+				}
+			}
+			continue
+		}
+		open = append(open, n.left, n.right)
+	}
+	return xs
+}
+
+// insert adds the object into the leaf node whose AABB would grow the least,
+// and splits the leaf if it is full.
+func (t *T) insert(x id.ID) {
+	r := t.buffers[x]
+
+	if t.root == nil {
+		t.root = &N{aabb: clone(r), ids: []id.ID{x}}
+		t.leaves[x] = t.root
+		return
+	}
+
+	n := t.root
+	for !n.IsLeaf() {
+		dl := unionArea(n.left.aabb, r) - area(n.left.aabb)
+		dr := unionArea(n.right.aabb, r) - area(n.right.aabb)
+		if dl < dr || (dl == dr && area(n.left.aabb) <= area(n.right.aabb)) {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	n.ids = append(n.ids, x)
+	t.leaves[x] = n
+	if len(n.ids) > t.leafSize {
+		n = t.split(n)
+	}
+	t.refit(n)
+}
+
+// split replaces the input leaf node with an internal node whose children
+// each contain half of the objects of the leaf, partitioned along the longer
+// axis of the leaf AABB. split returns the new internal node.
+func (t *T) split(n *N) *N {
+	t.fit(n)
+
+	// center returns twice the center of the object along the longer
+	// axis of the leaf.
+	center := func(x id.ID) float64 {
+		r := t.buffers[x]
+		return r.Min().X() + r.Max().X()
+	}
+	if d := vector.Sub(n.aabb.Max(), n.aabb.Min()); d.Y() > d.X() {
+		center = func(x id.ID) float64 {
+			r := t.buffers[x]
+			return r.Min().Y() + r.Max().Y()
+		}
+	}
+	sort.Slice(n.ids, func(i, j int) bool {
+		ci, cj := center(n.ids[i]), center(n.ids[j])
+		if ci == cj {
+			return n.ids[i] < n.ids[j]
+		}
+		return ci < cj
+	})
+
+	k := len(n.ids) / 2
+	l := &N{parent: n, ids: append([]id.ID{}, n.ids[:k]...)}
+	r := &N{parent: n, ids: append([]id.ID{}, n.ids[k:]...)}
+	for _, x := range l.ids {
+		t.leaves[x] = l
+	}
+	for _, x := range r.ids {
+		t.leaves[x] = r
+	}
+	t.fit(l)
+	t.fit(r)
+
+	n.left, n.right, n.ids = l, r, nil
+	return n
+}
+
+// remove removes the object from its leaf node, and removes the leaf node
+// from the tree if it is empty.
+func (t *T) remove(x id.ID) {
+	n := t.leaves[x]
+	delete(t.leaves, x)
+
+	for i, y := range n.ids {
+		if y == x {
+			n.ids = append(n.ids[:i], n.ids[i+1:]...)
+			break
+		}
+	}
+	if len(n.ids) > 0 {
+		t.refit(n)
+		return
+	}
+
+	p := n.parent
+	if p == nil {
+		t.root = nil
+		return
+	}
+
+	s := p.left
+	if s == n {
+		s = p.right
+	}
+	t.replace(p, s)
+	t.refit(s.parent)
+}
+
+// refit recalculates the AABB and height of the input node and its ancestors,
+// rebalancing the tree along the way. Ancestors are not visited once a node is
+// left unchanged.
+func (t *T) refit(n *N) {
+	for ; n != nil; n = n.parent {
+		if m := t.balance(n); m != n {
+			n = m
+			continue
+		}
+		if !t.fit(n) {
+			return
+		}
+	}
+}
+
+// fit recalculates the AABB and height of the input node from its children,
+// and reports if either has changed. The AABB is updated in place, as fit is
+// called on every mutation.
+func (t *T) fit(n *N) bool {
+	var old [4]float64
+	if n.aabb.Min() != nil {
+		old = [4]float64{n.aabb.Min().X(), n.aabb.Min().Y(), n.aabb.Max().X(), n.aabb.Max().Y()}
+	}
+	h := n.height
+
+	if n.IsLeaf() {
+		n.height = 0
+		set(&n.aabb, t.buffers[n.ids[0]])
+		for _, x := range n.ids[1:] {
+			extend(n.aabb, t.buffers[x])
+		}
+	} else {
+		n.height = 1 + n.left.height
+		if n.right.height > n.left.height {
+			n.height = 1 + n.right.height
+		}
+		set(&n.aabb, n.left.aabb)
+		extend(n.aabb, n.right.aabb)
+	}
+
+	return n.height != h || old != [4]float64{n.aabb.Min().X(), n.aabb.Min().Y(), n.aabb.Max().X(), n.aabb.Max().Y()}
+}
+
+// balance rotates the input node if the heights of its children differ by
+// more than one, and returns the root of the rotated subtree.
+func (t *T) balance(n *N) *N {
+	if n.IsLeaf() || n.height < 2 {
+		return n
+	}
+
+	switch d := n.right.height - n.left.height; {
+	case d > 1:
+		return t.rotate(n, n.right)
+	case d < -1:
+		return t.rotate(n, n.left)
+	default:
+		return n
+	}
+}
+
+// rotate promotes the input child c into the place of its parent n. The
+// taller child of c stays under c, and the shorter child takes the place of c
+// under n.
+func (t *T) rotate(n *N, c *N) *N {
+	keep, move := c.left, c.right
+	if move.height > keep.height {
+		keep, move = move, keep
+	}
+
+	t.replace(n, c)
+
+	if n.left == c {
+		n.left = move
+	} else {
+		n.right = move
+	}
+	move.parent = n
+
+	c.left, c.right = n, keep
+	n.parent = c
+
+	t.fit(n)
+	t.fit(c)
+	return c
+}
+
+// replace puts the node m into the place of the node n under the parent of n.
+func (t *T) replace(n *N, m *N) {
+	m.parent = n.parent
+	switch {
+	case n.parent == nil:
+		t.root = m
+	case n.parent.left == n:
+		n.parent.left = m
+	default:
+		n.parent.right = m
+	}
+}
+
+// buffer sets b to the input AABB expanded about its center such that its
+// area grows by the input factor.
+func buffer(b hyperrectangle.R, r hyperrectangle.R, tolerance float64) {
+	s := (math.Sqrt(tolerance) - 1) / 2
+	dx, dy := s*(r.Max().X()-r.Min().X()), s*(r.Max().Y()-r.Min().Y())
+	min, max := b.Min(), b.Max()
+	min[0], min[1] = r.Min().X()-dx, r.Min().Y()-dy
+	max[0], max[1] = r.Max().X()+dx, r.Max().Y()+dy
+}
+
+func clone(r hyperrectangle.R) hyperrectangle.R {
+	return *hyperrectangle.New(
+		vector.V{r.Min().X(), r.Min().Y()},
+		vector.V{r.Max().X(), r.Max().Y()},
+	)
+}
+
+func union(r hyperrectangle.R, s hyperrectangle.R) hyperrectangle.R {
+	return *hyperrectangle.New(
+		vector.V{math.Min(r.Min().X(), s.Min().X()), math.Min(r.Min().Y(), s.Min().Y())},
+		vector.V{math.Max(r.Max().X(), s.Max().X()), math.Max(r.Max().Y(), s.Max().Y())},
+	)
+}
+
+// set copies s into r, reusing the storage of r if it has been allocated.
+func set(r *hyperrectangle.R, s hyperrectangle.R) {
+	if r.Min() == nil {
+		*r = clone(s)
+		return
+	}
+	copy(r.Min(), s.Min())
+	copy(r.Max(), s.Max())
+}
+
+// extend grows r in place to also bound s.
+func extend(r hyperrectangle.R, s hyperrectangle.R) {
+	min, max := r.Min(), r.Max()
+	min[0], min[1] = math.Min(min[0], s.Min().X()), math.Min(min[1], s.Min().Y())
+	max[0], max[1] = math.Max(max[0], s.Max().X()), math.Max(max[1], s.Max().Y())
+}
+
+// intersect shrinks r in place to its intersection with s.
+func intersect(r hyperrectangle.R, s hyperrectangle.R) {
+	min, max := r.Min(), r.Max()
+	min[0], min[1] = math.Max(min[0], s.Min().X()), math.Max(min[1], s.Min().Y())
+	max[0], max[1] = math.Min(max[0], s.Max().X()), math.Min(max[1], s.Max().Y())
+}
+
+func area(r hyperrectangle.R) float64 {
+	return (r.Max().X() - r.Min().X()) * (r.Max().Y() - r.Min().Y())
+}
+
+// unionArea returns the area of the union of r and s without allocating.
+func unionArea(r hyperrectangle.R, s hyperrectangle.R) float64 {
+	w := math.Max(r.Max().X(), s.Max().X()) - math.Min(r.Min().X(), s.Min().X())
+	h := math.Max(r.Max().Y(), s.Max().Y()) - math.Min(r.Min().Y(), s.Min().Y())
+	return w * h
+}
+
+// contains checks if r fully contains s.
+func contains(r hyperrectangle.R, s hyperrectangle.R) bool {
+	return r.Min().X() <= s.Min().X() && r.Min().Y() <= s.Min().Y() &&
+		s.Max().X() <= r.Max().X() && s.Max().Y() <= r.Max().Y()
+}
@@ -0,0 +1,366 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/downflux/go-bvh/bvh"
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	dberrors "github.com/downflux/go-database/errors"
+	hnd "github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+func rect(x, y, w, h float64) hyperrectangle.R {
+	return *hyperrectangle.New(vector.V{x, y}, vector.V{x + w, y + h})
+}
+
+func random() hyperrectangle.R {
+	return rect(rand.Float64()*100, rand.Float64()*100, rand.Float64()*5, rand.Float64()*5)
+}
+
+// check verifies the structural invariants of the tree.
+func check(t *testing.T, tr *T) {
+	t.Helper()
+
+	n := 0
+	var walk func(m *N) int
+	walk = func(m *N) int {
+		if m.IsLeaf() {
+			if len(m.ids) == 0 || len(m.ids) > tr.leafSize {
+				t.Fatalf("len(IDs()) = %v, want in [1, %v]", len(m.ids), tr.leafSize)
+			}
+			for _, x := range m.ids {
+				if tr.leaves[x] != m {
+					t.Fatalf("leaves[%v] = %p, want = %p", x, tr.leaves[x], m)
+				}
+				if !contains(m.aabb, tr.data[x]) {
+					t.Fatalf("AABB() = %v, does not contain object %v", m.aabb, x)
+				}
+			}
+			n += len(m.ids)
+			return 0
+		}
+		for _, c := range []*N{m.left, m.right} {
+			if c.parent != m {
+				t.Fatalf("parent = %p, want = %p", c.parent, m)
+			}
+			if !contains(m.aabb, c.aabb) {
+				t.Fatalf("AABB() = %v, does not contain child %v", m.aabb, c.aabb)
+			}
+		}
+		h := walk(m.left)
+		if g := walk(m.right); g > h {
+			h = g
+		}
+		if m.height != h+1 {
+			t.Fatalf("height = %v, want = %v", m.height, h+1)
+		}
+		return h + 1
+	}
+
+	if tr.root != nil {
+		if tr.root.parent != nil {
+			t.Fatalf("root.parent = %p, want = nil", tr.root.parent)
+		}
+		walk(tr.root)
+	}
+	if n != len(tr.data) {
+		t.Fatalf("len(objects) = %v, want = %v", n, len(tr.data))
+	}
+}
+
+func TestT(t *testing.T) {
+	type config struct {
+		name string
+		o    O
+		n    int
+	}
+
+	configs := []config{
+		{name: "LeafSize=1", o: O{LeafSize: 1, Tolerance: 1}, n: 500},
+		{name: "LeafSize=8", o: O{LeafSize: 8, Tolerance: 1.15}, n: 500},
+		{name: "Tolerance=4", o: O{LeafSize: 4, Tolerance: 4}, n: 500},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			tr, err := New(c.o)
+			if err != nil {
+				t.Fatalf("New() = _, %v, want = _, %v", err, nil)
+			}
+			data := map[id.ID]hyperrectangle.R{}
+
+			for i := 0; i < c.n; i++ {
+				x := id.ID(i)
+				data[x] = random()
+				if err := tr.Insert(x, hnd.R(data[x])); err != nil {
+					t.Fatalf("Insert() = %v, want = %v", err, nil)
+				}
+			}
+			check(t, tr)
+
+			if err := tr.Insert(0, hnd.R(data[0])); err == nil {
+				t.Errorf("Insert() = %v, want a non-nil error", err)
+			}
+
+			for i := 0; i < c.n; i++ {
+				x := id.ID(rand.Intn(c.n))
+				if _, ok := data[x]; !ok {
+					continue
+				}
+				switch rand.Intn(3) {
+				case 0:
+					delete(data, x)
+					if err := tr.Remove(x); err != nil {
+						t.Fatalf("Remove() = %v, want = %v", err, nil)
+					}
+				case 1:
+					// Jitter the object within its buffer.
+					r := data[x]
+					data[x] = rect(r.Min().X()+0.01, r.Min().Y(), r.Max().X()-r.Min().X(), r.Max().Y()-r.Min().Y())
+					if err := tr.Update(x, hnd.R(data[x])); err != nil {
+						t.Fatalf("Update() = %v, want = %v", err, nil)
+					}
+				default:
+					data[x] = random()
+					if err := tr.Update(x, hnd.R(data[x])); err != nil {
+						t.Fatalf("Update() = %v, want = %v", err, nil)
+					}
+				}
+			}
+			check(t, tr)
+
+			for i := 0; i < 100; i++ {
+				q := rect(rand.Float64()*100, rand.Float64()*100, rand.Float64()*20, rand.Float64()*20)
+
+				var want []id.ID
+				for x, r := range data {
+					if !hnd.Disjoint(hnd.R(q), hnd.R(r)) {
+						want = append(want, x)
+					}
+				}
+				got := tr.BroadPhase(hnd.R(q))
+
+				sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+				sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("BroadPhase() = %v, want = %v", got, want)
+				}
+			}
+
+			for x := range data {
+				if err := tr.Remove(x); err != nil {
+					t.Fatalf("Remove() = %v, want = %v", err, nil)
+				}
+			}
+			if tr.Root() != nil {
+				t.Errorf("Root() = %v, want = nil", tr.Root())
+			}
+			if err := tr.Remove(0); err == nil {
+				t.Errorf("Remove() = %v, want a non-nil error", err)
+			}
+		})
+	}
+}
+
+func TestBalance(t *testing.T) {
+	tr, err := New(O{LeafSize: 1, Tolerance: 1})
+	if err != nil {
+		t.Fatalf("New() = _, %v, want = _, %v", err, nil)
+	}
+
+	// Sorted inserts degenerate into a linked list without rotations.
+	const n = 1024
+	for i := 0; i < n; i++ {
+		if err := tr.Insert(id.ID(i), hnd.R(rect(float64(i), 0, 1, 1))); err != nil {
+			t.Fatalf("Insert() = %v, want = %v", err, nil)
+		}
+	}
+	check(t, tr)
+
+	if got, want := tr.Root().height, 20; got > want {
+		t.Errorf("height = %v, want <= %v", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tolerance := range []float64{0, 0.5, math.NaN()} {
+		if _, err := New(O{LeafSize: 1, Tolerance: tolerance}); !errors.Is(err, dberrors.ErrInvalidOptions) {
+			t.Errorf("New() = _, %v, want = _, %v", err, dberrors.ErrInvalidOptions)
+		}
+	}
+}
+
+// TestConformance checks the tree returns the same results as bvh.T under a
+// random sequence of mutations.
+func TestConformance(t *testing.T) {
+	type config struct {
+		name string
+		o    O
+		n    int
+	}
+
+	configs := []config{
+		{name: "LeafSize=1", o: O{LeafSize: 1, Tolerance: 1}, n: 1000},
+		{name: "LeafSize=8", o: O{LeafSize: 8, Tolerance: 1.15}, n: 1000},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			tr, err := New(c.o)
+			if err != nil {
+				t.Fatalf("New() = _, %v, want = _, %v", err, nil)
+			}
+			want := bvh.New(bvh.O{K: 2, LeafSize: c.o.LeafSize, Tolerance: c.o.Tolerance})
+
+			sorted := func(xs []id.ID) string {
+				sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+				return fmt.Sprint(xs)
+			}
+
+			live := map[id.ID]bool{}
+			for i := 0; i < 4*c.n; i++ {
+				x := id.ID(rand.Intn(c.n))
+				r := hnd.R(random())
+
+				var got, exp error
+				switch {
+				case !live[x]:
+					live[x] = true
+					got, exp = tr.Insert(x, r), want.Insert(x, r)
+				case rand.Intn(3) == 0:
+					delete(live, x)
+					got, exp = tr.Remove(x), want.Remove(x)
+				default:
+					got, exp = tr.Update(x, r), want.Update(x, r)
+				}
+				if (got == nil) != (exp == nil) {
+					t.Fatalf("mutation %v on %v = %v, want = %v", i, x, got, exp)
+				}
+			}
+
+			if got, exp := sorted(tr.IDs()), sorted(want.IDs()); got != exp {
+				t.Fatalf("IDs() = %v, want = %v", got, exp)
+			}
+			for i := 0; i < 200; i++ {
+				q := hnd.R(rect(rand.Float64()*100, rand.Float64()*100, rand.Float64()*20, rand.Float64()*20))
+				if got, exp := sorted(tr.BroadPhase(q)), sorted(want.BroadPhase(q)); got != exp {
+					t.Fatalf("BroadPhase() = %v, want = %v", got, exp)
+				}
+
+				// Query with a filter which is not a plain overlap
+				// check, e.g. a circle.
+				p, d := vector.V{rand.Float64() * 100, rand.Float64() * 100}, rand.Float64()*10
+				f := func(r hnd.R) bool {
+					s := hyperrectangle.R(r)
+					dx := math.Max(0, math.Max(s.Min().X()-p.X(), p.X()-s.Max().X()))
+					dy := math.Max(0, math.Max(s.Min().Y()-p.Y(), p.Y()-s.Max().Y()))
+					return dx*dx+dy*dy <= d*d
+				}
+				if got, exp := sorted(tr.Query(f)), sorted(want.Query(f)); got != exp {
+					t.Fatalf("Query() = %v, want = %v", got, exp)
+				}
+			}
+		})
+	}
+}
+
+// indexes are the spatial indexes compared in benchmarks.
+var indexes = []struct {
+	name string
+	new  func() container.C
+}{
+	{
+		name: "tree",
+		new: func() container.C {
+			t, _ := New(O{LeafSize: 8, Tolerance: 1.15})
+			return t
+		},
+	},
+	{
+		name: "bvh",
+		new: func() container.C {
+			return bvh.New(bvh.O{K: 2, LeafSize: 8, Tolerance: 1.15})
+		},
+	},
+}
+
+// load returns n random AABBs spread over a square whose area grows with n, so
+// that the density of objects is constant.
+func load(n int) []hnd.R {
+	s := 10 * math.Sqrt(float64(n))
+	rs := make([]hnd.R, n)
+	for i := range rs {
+		rs[i] = hnd.R(rect(rand.Float64()*s, rand.Float64()*s, 1+rand.Float64(), 1+rand.Float64()))
+	}
+	return rs
+}
+
+func BenchmarkInsert(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		rs := load(n)
+		for _, c := range indexes {
+			b.Run(fmt.Sprintf("%v/N=%v", c.name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					t := c.new()
+					for x, r := range rs {
+						t.Insert(id.ID(x), r)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		rs := load(n)
+		for _, c := range indexes {
+			b.Run(fmt.Sprintf("%v/N=%v", c.name, n), func(b *testing.B) {
+				rs := append([]hnd.R{}, rs...)
+
+				t := c.new()
+				for x, r := range rs {
+					t.Insert(id.ID(x), r)
+				}
+
+				// Move each object by a small step per tick, as agents
+				// do in a game loop.
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					x := i % n
+					r := hyperrectangle.R(rs[x])
+					rs[x] = hnd.R(rect(r.Min().X()+0.1, r.Min().Y()+0.1, r.Max().X()-r.Min().X(), r.Max().Y()-r.Min().Y()))
+					t.Update(id.ID(x), rs[x])
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkBroadPhase(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		rs := load(n)
+		for _, c := range indexes {
+			b.Run(fmt.Sprintf("%v/N=%v", c.name, n), func(b *testing.B) {
+				t := c.new()
+				for x, r := range rs {
+					t.Insert(id.ID(x), r)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					t.BroadPhase(rs[i%n])
+				}
+			})
+		}
+	}
+}
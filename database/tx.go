@@ -0,0 +1,499 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/errors"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-database/internal/agent"
+	"github.com/downflux/go-database/internal/feature"
+	"github.com/downflux/go-database/internal/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	rofeature "github.com/downflux/go-database/feature"
+	roprojectile "github.com/downflux/go-database/projectile"
+)
+
+// Tx is a batch of buffered DB mutations, e.g. the set of proposed agent
+// moves for a tick, which are applied atomically on Commit.
+//
+// Buffered calls do not touch the DB, and are validated in order on Commit,
+// e.g. a Tx which sets the position of an agent deleted earlier in the same
+// Tx will fail to commit. If any mutation fails, or if Commit panics, all
+// mutations applied so far are reverted and the DB is left unchanged.
+//
+// Entity IDs are allocated when the insert is buffered, so that subsequent
+// calls in the same Tx may refer to the new entity. IDs of inserts which are
+// rolled back are not reused.
+//
+// A Tx is not safe for concurrent use, and may not be reused after Commit or
+// Rollback.
+type Tx struct {
+	db     *DB
+	ops    []func(c *commit) error
+	closed bool
+}
+
+// commit tracks the state of an in-progress Commit.
+type commit struct {
+	// undo is the list of closures which revert the mutations applied so
+	// far, in application order.
+	undo []func()
+
+	deleted []id.ID
+
+	// dirty entities have had their AABBs changed, and need their BVH
+	// entries updated before the commit completes.
+	agents      map[id.ID]bool
+	features    map[id.ID]bool
+	projectiles map[id.ID]bool
+}
+
+// Begin starts a new transaction on the DB.
+func (db *DB) Begin() *Tx { return &Tx{db: db} }
+
+// Commit applies all buffered mutations atomically. Commit acquires exclusive
+// access to the DB, and may be called concurrently with other DB operations.
+//
+// The DB is left unchanged if an error is returned.
+func (tx *Tx) Commit() error {
+	if tx.closed {
+		return fmt.Errorf("cannot commit transaction: %w: transaction is closed", errors.ErrInvalidOptions)
+	}
+	tx.closed = true
+
+	db := tx.db
+
+	db.agentsL.Lock()
+	defer db.agentsL.Unlock()
+
+	db.featuresL.Lock()
+	defer db.featuresL.Unlock()
+
+	db.projectilesL.Lock()
+	defer db.projectilesL.Unlock()
+
+	c := &commit{
+		agents:      map[id.ID]bool{},
+		features:    map[id.ID]bool{},
+		projectiles: map[id.ID]bool{},
+	}
+
+	// The rollback must also run if an op panics, so that the DB is left
+	// consistent for any caller which recovers from the panic.
+	ok := false
+	defer func() {
+		if !ok {
+			for i := len(c.undo) - 1; i >= 0; i-- {
+				c.undo[i]()
+			}
+			if err := c.sync(db); err != nil {
+				panic(fmt.Sprintf("cannot roll back transaction: %v", err))
+			}
+		}
+	}()
+
+	for _, op := range tx.ops {
+		if err := op(c); err != nil {
+			return fmt.Errorf("cannot commit transaction: %w", err)
+		}
+	}
+	if err := c.sync(db); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	ok = true
+
+	for _, x := range c.deleted {
+		db.detach(x)
+	}
+	return nil
+}
+
+// Rollback discards all buffered mutations.
+func (tx *Tx) Rollback() {
+	tx.closed = true
+	tx.ops = nil
+}
+
+// InsertAgent buffers the insertion of a new agent, and returns the ID the
+// agent will have once committed.
+func (tx *Tx) InsertAgent(o roagent.O) id.ID {
+	x := tx.db.id(KindAgent)
+	if !agent.Validate(agent.O(o)) {
+		tx.fail(fmt.Errorf("cannot insert agent: %w", errors.ErrInvalidOptions))
+		return x
+	}
+
+	a := agent.New(agent.O(o))
+	a.SetID(x)
+
+	tx.ops = append(tx.ops, func(c *commit) error {
+		if err := tx.db.agents.Insert(a); err != nil {
+			return err
+		}
+		tx.db.hide(x)
+		c.undo = append(c.undo, func() { die(tx.db.agents.Delete(x)) })
+		return nil
+	})
+	return x
+}
+
+// InsertFeature buffers the insertion of a new feature, and returns the ID the
+// feature will have once committed.
+func (tx *Tx) InsertFeature(o rofeature.O) id.ID {
+	x := tx.db.id(KindFeature)
+	if !feature.Validate(feature.O(o)) {
+		tx.fail(fmt.Errorf("cannot insert feature: %w", errors.ErrInvalidOptions))
+		return x
+	}
+
+	f := feature.New(feature.O(o))
+	f.SetID(x)
+
+	tx.ops = append(tx.ops, func(c *commit) error {
+		if err := tx.db.features.Insert(f); err != nil {
+			return err
+		}
+		tx.db.hide(x)
+		c.undo = append(c.undo, func() { die(tx.db.features.Delete(x)) })
+		return nil
+	})
+	return x
+}
+
+// InsertProjectile buffers the insertion of a new projectile, and returns the
+// ID the projectile will have once committed.
+func (tx *Tx) InsertProjectile(o roprojectile.O) id.ID {
+	x := tx.db.id(KindProjectile)
+	if !projectile.Validate(projectile.O(o)) {
+		tx.fail(fmt.Errorf("cannot insert projectile: %w", errors.ErrInvalidOptions))
+		return x
+	}
+
+	p := projectile.New(projectile.O(o))
+	p.SetID(x)
+
+	tx.ops = append(tx.ops, func(c *commit) error {
+		if err := tx.db.projectiles.Insert(p); err != nil {
+			return err
+		}
+		tx.db.hide(x)
+		c.undo = append(c.undo, func() { die(tx.db.projectiles.Delete(x)) })
+		return nil
+	})
+	return x
+}
+
+// DeleteAgent buffers the deletion of an agent. Any attached components are
+// removed once the transaction is committed.
+func (tx *Tx) DeleteAgent(x id.ID) {
+	tx.agent(x, false, func(a *agent.A) func() {
+		die(tx.db.agents.Delete(x))
+		return func() { die(tx.db.agents.Insert(a)) }
+	})
+}
+
+// DeleteFeature buffers the deletion of a feature. Any attached components
+// are removed once the transaction is committed.
+func (tx *Tx) DeleteFeature(x id.ID) {
+	tx.feature(x, false, func(f *feature.F) func() {
+		die(tx.db.features.Delete(x))
+		return func() { die(tx.db.features.Insert(f)) }
+	})
+}
+
+// DeleteProjectile buffers the deletion of a projectile. Any attached
+// components are removed once the transaction is committed.
+func (tx *Tx) DeleteProjectile(x id.ID) {
+	tx.projectile(x, false, func(p *projectile.P) func() {
+		die(tx.db.projectiles.Delete(x))
+		return func() { die(tx.db.projectiles.Insert(p)) }
+	})
+}
+
+// SetAgentPosition buffers a call to DB.SetAgentPosition.
+func (tx *Tx) SetAgentPosition(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.agent(x, true, func(a *agent.A) func() {
+		u := clone(a.Position())
+		a.SetPosition(v)
+		return func() { a.SetPosition(u) }
+	})
+}
+
+// SetAgentTargetPosition buffers a call to DB.SetAgentTargetPosition.
+func (tx *Tx) SetAgentTargetPosition(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.agent(x, false, func(a *agent.A) func() {
+		u := clone(a.TargetPosition())
+		a.SetTargetPosition(v)
+		return func() { a.SetTargetPosition(u) }
+	})
+}
+
+// SetAgentVelocity buffers a call to DB.SetAgentVelocity.
+func (tx *Tx) SetAgentVelocity(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.agent(x, false, func(a *agent.A) func() {
+		u := clone(a.Velocity())
+		a.SetVelocity(v)
+		return func() { a.SetVelocity(u) }
+	})
+}
+
+// SetAgentTargetVelocity buffers a call to DB.SetAgentTargetVelocity.
+func (tx *Tx) SetAgentTargetVelocity(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.agent(x, false, func(a *agent.A) func() {
+		u := clone(a.TargetVelocity())
+		a.SetTargetVelocity(v)
+		return func() { a.SetTargetVelocity(u) }
+	})
+}
+
+// SetAgentHeading buffers a call to DB.SetAgentHeading.
+func (tx *Tx) SetAgentHeading(x id.ID, v polar.V) {
+	v = polar.V(clone(vector.V(v)))
+	tx.agent(x, false, func(a *agent.A) func() {
+		u := polar.V(clone(vector.V(a.Heading())))
+		a.SetHeading(v)
+		return func() { a.SetHeading(u) }
+	})
+}
+
+// SetAgentMoveMode buffers a call to DB.SetAgentMoveMode.
+func (tx *Tx) SetAgentMoveMode(x id.ID, f move.F) {
+	if !move.Validate(f) {
+		tx.fail(fmt.Errorf("cannot set move mode %v for agent %v: %w", f, x, errors.ErrInvalidOptions))
+		return
+	}
+	tx.agent(x, false, func(a *agent.A) func() {
+		g := a.MoveMode()
+		a.SetMoveMode(f)
+		return func() { a.SetMoveMode(g) }
+	})
+}
+
+// SetAgentFlags buffers a call to DB.SetAgentFlags. Obstructions on the new
+// layer are checked on Commit against the state of the DB at that point in the
+// Tx, i.e. after all earlier mutations have been applied.
+func (tx *Tx) SetAgentFlags(x id.ID, f flags.F) {
+	tx.flags(x, func(flags.F) flags.F { return f })
+}
+
+// SetAgentLayer buffers a call to DB.SetAgentLayer.
+//
+// See SetAgentFlags for more information.
+func (tx *Tx) SetAgentLayer(x id.ID, l flags.F) {
+	if l&^flags.TerrainLayers != 0 {
+		tx.fail(fmt.Errorf("cannot set layer %v for agent %v: %w", l, x, errors.ErrInvalidOptions))
+		return
+	}
+	tx.flags(x, func(g flags.F) flags.F { return g&^flags.TerrainLayers | l })
+}
+
+// SetFeatureAABB buffers a call to DB.SetFeatureAABB.
+func (tx *Tx) SetFeatureAABB(x id.ID, aabb hyperrectangle.R) {
+	aabb = *hyperrectangle.New(clone(aabb.Min()), clone(aabb.Max()))
+	tx.feature(x, true, func(f *feature.F) func() {
+		r := f.AABB()
+		r = *hyperrectangle.New(clone(r.Min()), clone(r.Max()))
+		f.SetAABB(aabb)
+		return func() { f.SetAABB(r) }
+	})
+}
+
+// SetFeatureFlags buffers a call to DB.SetFeatureFlags.
+func (tx *Tx) SetFeatureFlags(x id.ID, g flags.F) {
+	if !flags.Validate(g) {
+		tx.fail(fmt.Errorf("cannot set flags %v for feature %v: %w", g, x, errors.ErrInvalidOptions))
+		return
+	}
+	tx.feature(x, false, func(f *feature.F) func() {
+		h := f.Flags()
+		f.SetFlags(g)
+		return func() { f.SetFlags(h) }
+	})
+}
+
+// SetFeatureTeam buffers a call to DB.SetFeatureTeam.
+func (tx *Tx) SetFeatureTeam(x id.ID, t team.F) {
+	tx.feature(x, false, func(f *feature.F) func() {
+		u := f.Team()
+		f.SetTeam(t)
+		return func() { f.SetTeam(u) }
+	})
+}
+
+// SetProjectilePosition buffers a call to DB.SetProjectilePosition.
+func (tx *Tx) SetProjectilePosition(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.projectile(x, true, func(p *projectile.P) func() {
+		u := clone(p.Position())
+		p.SetPosition(v)
+		return func() { p.SetPosition(u) }
+	})
+}
+
+// SetProjectileTargetPosition buffers a call to DB.SetProjectileTargetPosition.
+func (tx *Tx) SetProjectileTargetPosition(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.projectile(x, false, func(p *projectile.P) func() {
+		u := clone(p.TargetPosition())
+		p.SetTargetPosition(v)
+		return func() { p.SetTargetPosition(u) }
+	})
+}
+
+// SetProjectileVelocity buffers a call to DB.SetProjectileVelocity.
+func (tx *Tx) SetProjectileVelocity(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.projectile(x, false, func(p *projectile.P) func() {
+		u := clone(p.Velocity())
+		p.SetVelocity(v)
+		return func() { p.SetVelocity(u) }
+	})
+}
+
+// SetProjectileTargetVelocity buffers a call to DB.SetProjectileTargetVelocity.
+func (tx *Tx) SetProjectileTargetVelocity(x id.ID, v vector.V) {
+	v = clone(v)
+	tx.projectile(x, false, func(p *projectile.P) func() {
+		u := clone(p.TargetVelocity())
+		p.SetTargetVelocity(v)
+		return func() { p.SetTargetVelocity(u) }
+	})
+}
+
+// SetProjectileHeading buffers a call to DB.SetProjectileHeading.
+func (tx *Tx) SetProjectileHeading(x id.ID, v polar.V) {
+	v = polar.V(clone(vector.V(v)))
+	tx.projectile(x, false, func(p *projectile.P) func() {
+		u := polar.V(clone(vector.V(p.Heading())))
+		p.SetHeading(v)
+		return func() { p.SetHeading(u) }
+	})
+}
+
+// fail buffers an op which always fails, e.g. due to invalid input.
+func (tx *Tx) fail(err error) {
+	tx.ops = append(tx.ops, func(*commit) error { return err })
+}
+
+// agent buffers an op on an existing agent. The input function applies the
+// mutation and returns a closure which reverts it. If dirty is set, the
+// agent BVH entry is updated once all ops have been applied.
+func (tx *Tx) agent(x id.ID, dirty bool, fn func(a *agent.A) func()) {
+	tx.ops = append(tx.ops, func(c *commit) error {
+		a, err := tx.db.agents.Get(x)
+		if err != nil {
+			return err
+		}
+		tx.db.preserveAgent(a)
+		c.undo = append(c.undo, fn(a))
+		if tx.db.agents.At(x) == nil {
+			c.deleted = append(c.deleted, x)
+		}
+		if dirty {
+			c.agents[x] = true
+		}
+		return nil
+	})
+}
+
+// flags buffers a flags transition on an existing agent. The input function
+// returns the new flags given the current flags of the agent.
+func (tx *Tx) flags(x id.ID, fn func(g flags.F) flags.F) {
+	tx.ops = append(tx.ops, func(c *commit) error {
+		a, err := tx.db.agents.Get(x)
+		if err != nil {
+			return err
+		}
+
+		// The obstruction check queries the BVHs, which must reflect
+		// all AABB changes made earlier in the Tx.
+		if err := c.sync(tx.db); err != nil {
+			return err
+		}
+
+		g := a.Flags()
+		if err := tx.db.setAgentFlags(a, fn(g)); err != nil {
+			return err
+		}
+		c.undo = append(c.undo, func() { a.RestoreFlags(g) })
+		return nil
+	})
+}
+
+func (tx *Tx) feature(x id.ID, dirty bool, fn func(f *feature.F) func()) {
+	tx.ops = append(tx.ops, func(c *commit) error {
+		f, err := tx.db.features.Get(x)
+		if err != nil {
+			return err
+		}
+		tx.db.preserveFeature(f)
+		c.undo = append(c.undo, fn(f))
+		if tx.db.features.At(x) == nil {
+			c.deleted = append(c.deleted, x)
+		}
+		if dirty {
+			c.features[x] = true
+		}
+		return nil
+	})
+}
+
+func (tx *Tx) projectile(x id.ID, dirty bool, fn func(p *projectile.P) func()) {
+	tx.ops = append(tx.ops, func(c *commit) error {
+		p, err := tx.db.projectiles.Get(x)
+		if err != nil {
+			return err
+		}
+		tx.db.preserveProjectile(p)
+		c.undo = append(c.undo, fn(p))
+		if tx.db.projectiles.At(x) == nil {
+			c.deleted = append(c.deleted, x)
+		}
+		if dirty {
+			c.projectiles[x] = true
+		}
+		return nil
+	})
+}
+
+// sync updates the BVH entries of all dirty entities which still exist in the
+// DB.
+func (c *commit) sync(db *DB) error {
+	for x := range c.agents {
+		if db.agents.At(x) != nil {
+			if err := db.agents.Update(x); err != nil {
+				return err
+			}
+		}
+	}
+	for x := range c.features {
+		if db.features.At(x) != nil {
+			if err := db.features.Update(x); err != nil {
+				return err
+			}
+		}
+	}
+	for x := range c.projectiles {
+		if db.projectiles.At(x) != nil {
+			if err := db.projectiles.Update(x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of the input vector, which may otherwise alias the
+// internal state of an entity or caller.
+func clone(v vector.V) vector.V { return vector.V{v.X(), v.Y()} }
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	roagent "github.com/downflux/go-database/agent"
+	dberrors "github.com/downflux/go-database/errors"
+)
+
+func TestTx(t *testing.T) {
+	o := roagent.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		Size:           size.FSmall,
+	}
+
+	type config struct {
+		name string
+		// tx buffers mutations given the ID of an agent at the origin,
+		// and returns the ID of the agent whose position should be
+		// checked after the commit.
+		tx   func(tx *Tx, x id.ID) id.ID
+		want error
+		// p is the expected position of the checked agent, or nil if
+		// the agent should not exist.
+		p vector.V
+	}
+
+	configs := []config{
+		{
+			name: "Commit",
+			tx: func(tx *Tx, x id.ID) id.ID {
+				tx.SetAgentPosition(x, vector.V{5, 5})
+				tx.SetAgentPosition(x, vector.V{10, 10})
+				return x
+			},
+			want: nil,
+			p:    vector.V{10, 10},
+		},
+		{
+			name: "Commit/Insert",
+			tx: func(tx *Tx, x id.ID) id.ID {
+				y := tx.InsertAgent(o)
+				tx.SetAgentPosition(y, vector.V{10, 10})
+				return y
+			},
+			want: nil,
+			p:    vector.V{10, 10},
+		},
+		{
+			name: "Commit/Delete",
+			tx: func(tx *Tx, x id.ID) id.ID {
+				tx.DeleteAgent(x)
+				return x
+			},
+			want: nil,
+			p:    nil,
+		},
+		{
+			name: "Rollback/NotFound",
+			tx: func(tx *Tx, x id.ID) id.ID {
+				tx.SetAgentPosition(x, vector.V{10, 10})
+				tx.DeleteAgent(x)
+				tx.SetAgentVelocity(x, vector.V{1, 1})
+				return x
+			},
+			want: dberrors.ErrNotFound,
+			p:    vector.V{0, 0},
+		},
+		{
+			name: "Rollback/Insert",
+			tx: func(tx *Tx, x id.ID) id.ID {
+				y := tx.InsertAgent(o)
+				tx.SetAgentMoveMode(x, move.FSeek|move.FArrival)
+				return y
+			},
+			want: dberrors.ErrInvalidOptions,
+			p:    nil,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := New(DefaultO)
+			x := db.InsertAgent(o).ID()
+			value := Register[int](db)
+			value.Attach(x, 1)
+
+			tx := db.Begin()
+			y := c.tx(tx, x)
+			if err := tx.Commit(); !errors.Is(err, c.want) {
+				t.Fatalf("Commit() = %v, want = %v", err, c.want)
+			}
+
+			a, err := db.GetAgent(y)
+			if c.p == nil {
+				if err == nil {
+					t.Fatalf("GetAgent() = %v, want = nil", a)
+				}
+				if y == x && value.Has(x) {
+					t.Errorf("Has() = %v, want = %v", true, false)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetAgent() = _, %v, want = _, nil", err)
+			}
+			if !vector.Within(a.Position(), c.p) {
+				t.Errorf("Position() = %v, want = %v", a.Position(), c.p)
+			}
+
+			// Ensure the BVH is consistent with the agent position.
+			if got := db.QueryAgents(a.AABB(), func(roagent.RO) bool { return true }); len(got) != 1 {
+				t.Errorf("QueryAgents() = %v, want 1 agent", got)
+			}
+			if got := db.QueryAgents(*hyperrectangle.New(
+				vector.V{3, 3}, vector.V{7, 7},
+			), func(roagent.RO) bool { return true }); len(got) != 0 {
+				t.Errorf("QueryAgents() = %v, want = []", got)
+			}
+		})
+	}
+
+	t.Run("Closed", func(t *testing.T) {
+		tx := New(DefaultO).Begin()
+		tx.Rollback()
+		if err := tx.Commit(); !errors.Is(err, dberrors.ErrInvalidOptions) {
+			t.Errorf("Commit() = %v, want = %v", err, dberrors.ErrInvalidOptions)
+		}
+	})
+}
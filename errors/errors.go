@@ -0,0 +1,34 @@
+// Package errors defines the sentinel errors returned by the database.
+//
+// Callers should check for these with the standard library errors.Is, as the
+// database wraps them with additional context, e.g.
+//
+//	if _, err := db.GetAgent(x); errors.Is(err, dberrors.ErrNotFound) { ... }
+package errors
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotFound indicates the requested entity does not exist in the
+	// database.
+	ErrNotFound = errors.New("entity not found")
+
+	// ErrInvalidOptions indicates the input options or mutation would
+	// result in an invalid entity, e.g. an agent with zero radius.
+	ErrInvalidOptions = errors.New("invalid options")
+
+	// ErrIndex indicates the underlying spatial index (i.e. BVH) rejected
+	// an operation.
+	ErrIndex = errors.New("index error")
+
+	// ErrBlocked indicates the mutation is valid in isolation but is
+	// obstructed by another entity, e.g. an aircraft landing on top of a
+	// tank.
+	ErrBlocked = errors.New("blocked")
+
+	// ErrCorrupt indicates serialized data is malformed, or was written
+	// with an unsupported encoding version.
+	ErrCorrupt = errors.New("corrupt data")
+)
@@ -4,6 +4,7 @@ import (
 	"github.com/downflux/go-database/agent"
 	"github.com/downflux/go-database/feature"
 	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/team"
 	"github.com/downflux/go-geometry/2d/hyperrectangle"
 	"github.com/downflux/go-geometry/2d/vector"
 
@@ -17,9 +18,10 @@ func AgentOnDifferentLayers(a agent.RO, b agent.RO) bool {
 	return (m^n)&flags.FTerrainAir == flags.FTerrainAir
 }
 
-// AgentIsSquishable checks if the agent a may be run over by b.
+// AgentIsSquishable checks if the agent a may be run over by b, i.e. the two
+// agents are not allied per team.Default, and b is larger than a.
 func AgentIsSquishable(a agent.RO, b agent.RO) bool {
-	if AgentIsTeammate(a, b) {
+	if AgentIsAlly(a, b) {
 		return false
 	}
 	if AgentOnDifferentLayers(a, b) {
@@ -30,6 +32,16 @@ func AgentIsSquishable(a agent.RO, b agent.RO) bool {
 
 func AgentIsTeammate(a agent.RO, b agent.RO) bool { return a.Team() == b.Team() }
 
+// AgentIsAlly checks if the agents' teams are allied per team.Default.
+func AgentIsAlly(a agent.RO, b agent.RO) bool {
+	return team.Default().Relation(a.Team(), b.Team()) == team.RAlly
+}
+
+// AgentIsEnemy checks if the agents' teams are hostile per team.Default.
+func AgentIsEnemy(a agent.RO, b agent.RO) bool {
+	return team.Default().Relation(a.Team(), b.Team()) == team.REnemy
+}
+
 // AgentIsColliding checks if two agents are actually physically overlapping.
 func AgentIsColliding(a agent.RO, b agent.RO) bool {
 	if a.ID() == b.ID() {
@@ -6,6 +6,8 @@ import (
 	"github.com/downflux/go-database/agent"
 	"github.com/downflux/go-database/agent/mock"
 	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
 	"github.com/downflux/go-geometry/2d/vector"
 	"github.com/downflux/go-geometry/2d/vector/polar"
 )
@@ -86,3 +88,65 @@ func TestAgentIsColliding(t *testing.T) {
 		})
 	}
 }
+
+func TestAgentIsSquishable(t *testing.T) {
+	defer team.SetDefault(team.Default())
+	team.SetDefault(team.New().With(1, 2, team.RAlly))
+
+	type config struct {
+		name string
+		a    agent.RO
+		b    agent.RO
+		want bool
+	}
+
+	o := func(t team.F, s size.F) agent.O {
+		return agent.O{
+			Heading: polar.V{1, 0},
+			Flags:   flags.FTerrainLand | flags.FTerrainAccessibleLand,
+			Team:    t,
+			Size:    s,
+		}
+	}
+
+	configs := []config{
+		{
+			name: "Teammate",
+			a:    mock.New(1, o(1, size.FSmall)),
+			b:    mock.New(2, o(1, size.FLarge)),
+			want: false,
+		},
+		{
+			name: "Ally",
+			a:    mock.New(1, o(1, size.FSmall)),
+			b:    mock.New(2, o(2, size.FLarge)),
+			want: false,
+		},
+		{
+			name: "Neutral",
+			a:    mock.New(1, o(team.FNeutral, size.FSmall)),
+			b:    mock.New(2, o(2, size.FLarge)),
+			want: true,
+		},
+		{
+			name: "Enemy",
+			a:    mock.New(1, o(1, size.FSmall)),
+			b:    mock.New(2, o(3, size.FLarge)),
+			want: true,
+		},
+		{
+			name: "Enemy/Smaller",
+			a:    mock.New(1, o(1, size.FLarge)),
+			b:    mock.New(2, o(3, size.FSmall)),
+			want: false,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := AgentIsSquishable(c.a, c.b); got != c.want {
+				t.Errorf("AgentIsSquishable() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
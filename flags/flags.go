@@ -24,6 +24,9 @@ const (
 	TerrainAirCheck  = FTerrainAccessibleAir | FTerrainAir
 	TerrainLandCheck = FTerrainAccessibleLand | FTerrainLand
 	TerrainSeaCheck  = FTerrainAccessibleSea | FTerrainSea
+
+	// TerrainLayers is the mask of all occupiable map layers.
+	TerrainLayers = FTerrainAir | FTerrainLand | FTerrainSea
 )
 
 // Validate ensures the input mask is valid. Additional checks may be added on
@@ -41,3 +44,24 @@ func Validate(f F) bool {
 
 	return true
 }
+
+// ValidateStrict additionally ensures the input mask occupies exactly one map
+// layer.
+//
+// Validate defines the set of valid agent flags, e.g. at insertion time, while
+// ValidateStrict is only required of the target of a layer transition, e.g.
+// via database.DB.SetAgentFlags.
+func ValidateStrict(f F) bool {
+	if !Validate(f) {
+		return false
+	}
+	switch Layer(f) {
+	case FTerrainAir, FTerrainLand, FTerrainSea:
+		return true
+	default:
+		return false
+	}
+}
+
+// Layer returns the map layer(s) currently occupied by the input mask.
+func Layer(f F) F { return f & TerrainLayers }
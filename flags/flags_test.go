@@ -32,3 +32,42 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateStrict(t *testing.T) {
+	type config struct {
+		name string
+		f    F
+		want bool
+	}
+
+	configs := []config{
+		{
+			name: "Valid/TerrainAir",
+			f:    FTerrainAccessibleAir | FTerrainAccessibleLand | FTerrainAir,
+			want: true,
+		},
+		{
+			name: "Invalid/NoLayer",
+			f:    FTerrainAccessibleAir | FTerrainAccessibleLand,
+			want: false,
+		},
+		{
+			name: "Invalid/MultipleLayers",
+			f:    FTerrainAccessibleAir | FTerrainAccessibleLand | FTerrainAir | FTerrainLand,
+			want: false,
+		},
+		{
+			name: "Invalid/Inaccessible",
+			f:    FTerrainAccessibleAir | FTerrainLand,
+			want: false,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := ValidateStrict(c.f); got != c.want {
+				t.Errorf("ValidateStrict() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package team
 
+import (
+	"math"
+	"sync/atomic"
+)
+
 // F defines the team of an object. This team is distinct from the
 // faction and player.
 type F uint8
@@ -7,3 +12,101 @@ type F uint8
 const (
 	FNeutral F = iota
 )
+
+// R defines the relation between two teams.
+type R uint8
+
+const (
+	RNeutral R = iota
+	RAlly
+	REnemy
+)
+
+var (
+	// def is the relation matrix consulted by the filters package.
+	def atomic.Pointer[M]
+)
+
+func init() { def.Store(New()) }
+
+// Default returns the relation matrix consulted by the filters package.
+func Default() *M { return def.Load() }
+
+// SetDefault replaces the relation matrix consulted by the filters package,
+// e.g.
+//
+//	team.SetDefault(team.Default().With(1, 2, team.RAlly))
+//
+// Concurrent readers observe either the old or the new matrix in full.
+func SetDefault(m *M) { def.Store(m) }
+
+// M is a symmetric matrix of relations between teams. Unless explicitly set,
+//
+//  1. a team is allied with itself,
+//  1. FNeutral is neutral towards all other teams, and
+//  1. all other pairs of teams are enemies.
+//
+// M is immutable, and may be read concurrently.
+type M struct {
+	relations map[[2]F]R
+}
+
+func New() *M {
+	return &M{
+		relations: make(map[[2]F]R, 16),
+	}
+}
+
+// With returns a copy of the matrix in which the teams a and b have the input
+// relation.
+func (m *M) With(a F, b F, r R) *M {
+	n := m.clone()
+	n.relations[key(a, b)] = r
+	return n
+}
+
+// WithAll returns a copy of the matrix in which the team a has the input
+// relation with all other teams, e.g. to declare a team which is hostile to
+// everyone.
+func (m *M) WithAll(a F, r R) *M {
+	n := m.clone()
+	for b := 0; b <= math.MaxUint8; b++ {
+		if F(b) != a {
+			n.relations[key(a, F(b))] = r
+		}
+	}
+	return n
+}
+
+// Relation returns the relation between the teams a and b.
+func (m *M) Relation(a F, b F) R {
+	if r, ok := m.relations[key(a, b)]; ok {
+		return r
+	}
+
+	switch {
+	case a == b:
+		return RAlly
+	case a == FNeutral || b == FNeutral:
+		return RNeutral
+	default:
+		return REnemy
+	}
+}
+
+func (m *M) clone() *M {
+	n := &M{
+		relations: make(map[[2]F]R, len(m.relations)+1),
+	}
+	for k, r := range m.relations {
+		n.relations[k] = r
+	}
+	return n
+}
+
+func key(a F, b F) [2]F {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]F{a, b}
+}
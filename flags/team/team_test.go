@@ -0,0 +1,66 @@
+package team
+
+import (
+	"testing"
+)
+
+func TestRelation(t *testing.T) {
+	m := New().With(2, 1, RAlly).With(3, 3, REnemy).WithAll(4, REnemy)
+
+	type config struct {
+		name string
+		a    F
+		b    F
+		want R
+	}
+
+	configs := []config{
+		{name: "Default/Self", a: 5, b: 5, want: RAlly},
+		{name: "Default/Neutral", a: FNeutral, b: 5, want: RNeutral},
+		{name: "Default/Enemy", a: 5, b: 6, want: REnemy},
+		{name: "Set", a: 1, b: 2, want: RAlly},
+		{name: "Set/Symmetric", a: 2, b: 1, want: RAlly},
+		{name: "Set/Self", a: 3, b: 3, want: REnemy},
+		{name: "SetAll", a: FNeutral, b: 4, want: REnemy},
+		{name: "SetAll/Self", a: 4, b: 4, want: RAlly},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := m.Relation(c.a, c.b); got != c.want {
+				t.Errorf("Relation() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWith(t *testing.T) {
+	m := New()
+	n := m.With(1, 2, RAlly)
+	o := n.WithAll(1, REnemy)
+
+	type config struct {
+		name string
+		m    *M
+		a    F
+		b    F
+		want R
+	}
+
+	// Derived matrices must not modify the matrix they are derived from.
+	configs := []config{
+		{name: "Original", m: m, a: 1, b: 2, want: REnemy},
+		{name: "With", m: n, a: 1, b: 2, want: RAlly},
+		{name: "With/Neutral", m: n, a: 1, b: FNeutral, want: RNeutral},
+		{name: "WithAll", m: o, a: 1, b: 2, want: REnemy},
+		{name: "WithAll/Neutral", m: o, a: 1, b: FNeutral, want: REnemy},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.m.Relation(c.a, c.b); got != c.want {
+				t.Errorf("Relation() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -6,9 +6,10 @@ import (
 
 	"github.com/downflux/go-geometry/2d/hyperrectangle"
 	"github.com/downflux/go-geometry/2d/hypersphere"
-	"github.com/downflux/go-geometry/2d/line"
 	"github.com/downflux/go-geometry/2d/vector"
 	"github.com/downflux/go-geometry/epsilon"
+
+	dhs "github.com/downflux/go-database/geometry/hypersphere"
 )
 
 type Side uint64
@@ -95,52 +96,107 @@ func Normal(r hyperrectangle.R, v vector.V) (float64, vector.V) {
 	return d, n.V()
 }
 
-// IntersectCircle checks if a circle overlaps an AABB. Note that this can be
-// decomposed into three checks --
-//
-//  1. if the circle center lies inside the rectangle,
-//  1. if the an edge of the rectangle crosses the circle at some point, and
-//  1. if the rectangle lies entirely within the circle, but does not overlap the
-//     circle center
-//
-// See https://stackoverflow.com/a/402019/873865 for more information.
+// IntersectCircle checks if a circle overlaps an AABB. This is done by finding
+// the point in the AABB closest to the circle center, and checking if that
+// point lies within the circle.
 func IntersectCircle(r hyperrectangle.R, p vector.V, radius float64) bool {
-	if r.In(vector.V(p)) {
-		return true
+	x := math.Max(r.Min().X(), math.Min(p.X(), r.Max().X()))
+	y := math.Max(r.Min().Y(), math.Min(p.Y(), r.Max().Y()))
+
+	return vector.SquaredMagnitude(vector.Sub(vector.V{x, y}, p)) <= radius*radius
+}
+
+// IntersectRay finds the first point along the ray p + t * d, t >= 0, which
+// lies in the AABB, using the slab method. IntersectRay returns the parametric
+// value t of the intersection and the outward normal of the AABB edge through
+// which the ray enters. If p lies in the AABB, the returned t-value is zero
+// and the normal faces against the ray direction.
+//
+// The returned t-value is the distance to the intersection iff d is a unit
+// vector.
+func IntersectRay(r hyperrectangle.R, p vector.V, d vector.V) (float64, vector.V, bool) {
+	if r.In(p) {
+		return 0, vector.Scale(-1, vector.Unit(d)), true
 	}
 
-	c := *hypersphere.New(p, radius)
+	tmin, tmax := math.Inf(-1), math.Inf(1)
+	n := vector.V{0, 0}
 
-	xmin, ymin := r.Min().X(), r.Min().Y()
-	xmax, ymax := r.Max().X(), r.Max().Y()
+	for i, x := range []float64{p.X(), p.Y()} {
+		min, max := r.Min()[i], r.Max()[i]
+		if d[i] == 0 {
+			if x < min || x > max {
+				return 0, nil, false
+			}
+			continue
+		}
 
-	// Check corners.
-	if c.In(vector.V{xmin, ymin}) {
-		return true
-	}
-	if c.In(vector.V{xmin, ymax}) {
-		return true
-	}
-	if c.In(vector.V{xmax, ymax}) {
-		return true
-	}
-	if c.In(vector.V{xmax, ymin}) {
-		return true
+		t0, t1 := (min-x)/d[i], (max-x)/d[i]
+		s := -1.0
+		if t0 > t1 {
+			t0, t1 = t1, t0
+			s = 1
+		}
+		if t0 > tmin {
+			tmin = t0
+			n = vector.V{0, 0}
+			n[i] = s
+		}
+		if t1 < tmax {
+			tmax = t1
+		}
 	}
 
-	// Check edges.
-	if _, _, ok := line.New(vector.V{xmin, ymin}, vector.V{0, 1}).IntersectCircle(c); ok {
-		return true
+	if tmin > tmax || tmin < 0 {
+		return 0, nil, false
 	}
-	if _, _, ok := line.New(vector.V{xmin, ymax}, vector.V{1, 0}).IntersectCircle(c); ok {
-		return true
+	return tmin, n, true
+}
+
+// SweepCircle finds the first point along the path p + t * d, t >= 0, at which
+// a circle of the input radius centered on the path touches the AABB.
+// SweepCircle returns the parametric value t of the contact and the outward
+// normal of the AABB at the contact point. If the circle already overlaps the
+// AABB at p, the returned t-value is zero and the normal faces against the
+// direction of motion.
+//
+// The set of circle centers which overlap the AABB is the AABB expanded by the
+// radius with rounded corners, i.e. the union of the AABB expanded along each
+// axis separately and the circles centered at the four corners of the AABB.
+// The first contact is then the first intersection of the path with any of
+// these shapes.
+func SweepCircle(r hyperrectangle.R, p vector.V, d vector.V, radius float64) (float64, vector.V, bool) {
+	if IntersectCircle(r, p, radius) {
+		return 0, vector.Scale(-1, vector.Unit(d)), true
 	}
-	if _, _, ok := line.New(vector.V{xmax, ymax}, vector.V{0, -1}).IntersectCircle(c); ok {
-		return true
+
+	xmin, ymin := r.Min().X(), r.Min().Y()
+	xmax, ymax := r.Max().X(), r.Max().Y()
+
+	var t float64
+	var n vector.V
+	var ok bool
+
+	for _, s := range []hyperrectangle.R{
+		*hyperrectangle.New(vector.V{xmin - radius, ymin}, vector.V{xmax + radius, ymax}),
+		*hyperrectangle.New(vector.V{xmin, ymin - radius}, vector.V{xmax, ymax + radius}),
+	} {
+		if u, m, hit := IntersectRay(s, p, d); hit && (!ok || u < t) {
+			t, n, ok = u, m, true
+		}
 	}
-	if _, _, ok := line.New(vector.V{xmax, ymin}, vector.V{-1, 0}).IntersectCircle(c); ok {
-		return true
+
+	for _, c := range []vector.V{
+		{xmin, ymin},
+		{xmin, ymax},
+		{xmax, ymin},
+		{xmax, ymax},
+	} {
+		if u, hit := dhs.IntersectRay(*hypersphere.New(c, radius), p, d); hit && (!ok || u < t) {
+			t, ok = u, true
+			n = vector.Unit(vector.Sub(vector.Add(p, vector.Scale(u, d)), c))
+		}
 	}
 
-	return false
+	return t, n, ok
 }
@@ -131,6 +131,13 @@ func TestIntersectCircle(t *testing.T) {
 			radius: 2,
 			want:   true,
 		},
+		{
+			name:   "Outside/EdgeExtension",
+			r:      *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}),
+			p:      vector.V{-1, -2},
+			radius: 2,
+			want:   false,
+		},
 		{
 			name:   "Outside",
 			r:      *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}),
@@ -148,3 +155,162 @@ func TestIntersectCircle(t *testing.T) {
 		})
 	}
 }
+
+func TestIntersectRay(t *testing.T) {
+	r := *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})
+
+	type config struct {
+		name  string
+		p     vector.V
+		d     vector.V
+		ok    bool
+		wantT float64
+		wantN vector.V
+	}
+
+	configs := []config{
+		{
+			name:  "West",
+			p:     vector.V{-5, 5},
+			d:     vector.V{1, 0},
+			ok:    true,
+			wantT: 5,
+			wantN: vector.V{-1, 0},
+		},
+		{
+			name:  "North",
+			p:     vector.V{5, 15},
+			d:     vector.V{0, -2},
+			ok:    true,
+			wantT: 2.5,
+			wantN: vector.V{0, 1},
+		},
+		{
+			name:  "Diagonal",
+			p:     vector.V{-1, -2},
+			d:     vector.V{1, 1},
+			ok:    true,
+			wantT: 2,
+			wantN: vector.V{0, -1},
+		},
+		{
+			name:  "Inside",
+			p:     vector.V{5, 5},
+			d:     vector.V{1, 0},
+			ok:    true,
+			wantT: 0,
+			wantN: vector.V{-1, 0},
+		},
+		{
+			name: "Miss/Behind",
+			p:    vector.V{-5, 5},
+			d:    vector.V{-1, 0},
+			ok:   false,
+		},
+		{
+			name: "Miss/Parallel",
+			p:    vector.V{-5, 11},
+			d:    vector.V{1, 0},
+			ok:   false,
+		},
+		{
+			name: "Miss/Diagonal",
+			p:    vector.V{-1, -12},
+			d:    vector.V{1, 1},
+			ok:   false,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			gotT, gotN, ok := IntersectRay(r, c.p, c.d)
+			if ok != c.ok {
+				t.Fatalf("IntersectRay() = _, _, %v, want = _, _, %v", ok, c.ok)
+			}
+			if ok && (!epsilon.Within(gotT, c.wantT) || !vector.Within(gotN, c.wantN)) {
+				t.Errorf("IntersectRay() = %v, %v, _, want = %v, %v, _", gotT, gotN, c.wantT, c.wantN)
+			}
+		})
+	}
+}
+
+func TestSweepCircle(t *testing.T) {
+	r := *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})
+
+	type config struct {
+		name   string
+		p      vector.V
+		d      vector.V
+		radius float64
+		ok     bool
+		wantT  float64
+		wantN  vector.V
+	}
+
+	configs := []config{
+		{
+			name:   "Edge",
+			p:      vector.V{-5, 5},
+			d:      vector.V{1, 0},
+			radius: 1,
+			ok:     true,
+			wantT:  4,
+			wantN:  vector.V{-1, 0},
+		},
+		{
+			name:   "Corner",
+			p:      vector.V{-5, -5},
+			d:      vector.V{1, 1},
+			radius: 1,
+			ok:     true,
+			wantT:  5 - 1/math.Sqrt(2),
+			wantN:  vector.Unit(vector.V{-1, -1}),
+		},
+		{
+			// The path passes through the corner of the expanded
+			// AABB, but first touches the rounded corner.
+			name:   "Corner/Graze",
+			p:      vector.V{-5, 10.6},
+			d:      vector.V{1, 0},
+			radius: 1,
+			ok:     true,
+			wantT:  4.2,
+			wantN:  vector.V{-0.8, 0.6},
+		},
+		{
+			name:   "Overlap",
+			p:      vector.V{-0.5, 5},
+			d:      vector.V{-1, 0},
+			radius: 1,
+			ok:     true,
+			wantT:  0,
+			wantN:  vector.V{1, 0},
+		},
+		{
+			name:   "Miss",
+			p:      vector.V{-5, 12},
+			d:      vector.V{1, 0},
+			radius: 1,
+			ok:     false,
+		},
+		{
+			name:   "Miss/Corner",
+			p:      vector.V{-5, -20},
+			d:      vector.V{1, 1},
+			radius: 1,
+			ok:     false,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			gotT, gotN, ok := SweepCircle(r, c.p, c.d, c.radius)
+			if ok != c.ok {
+				t.Fatalf("SweepCircle() = _, _, %v, want = _, _, %v", ok, c.ok)
+			}
+			if ok && (!epsilon.Within(gotT, c.wantT) || !vector.Within(gotN, c.wantN)) {
+				t.Errorf("SweepCircle() = %v, %v, _, want = %v, %v, _", gotT, gotN, c.wantT, c.wantN)
+			}
+		})
+	}
+}
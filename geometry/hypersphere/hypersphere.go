@@ -0,0 +1,48 @@
+package hypersphere
+
+import (
+	"math"
+
+	"github.com/downflux/go-geometry/2d/hypersphere"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// IntersectRay finds the first point along the ray p + t * d, t >= 0, which
+// lies in the circle, and returns the parametric value t of the intersection.
+// If p lies in the circle, the returned t-value is zero.
+//
+// The returned t-value is the distance to the intersection iff d is a unit
+// vector.
+func IntersectRay(c hypersphere.C, p vector.V, d vector.V) (float64, bool) {
+	if c.In(p) {
+		return 0, true
+	}
+
+	// Solve for ||p + t * d - c||² = r², i.e.
+	//
+	//   a * t² + 2b * t + k = 0
+	//
+	// where a = d • d, b = d • (p - c), and k = ||p - c||² - r².
+	u := vector.Sub(p, c.P())
+
+	a := vector.SquaredMagnitude(d)
+	b := vector.Dot(d, u)
+	k := vector.SquaredMagnitude(u) - c.R()*c.R()
+
+	if a == 0 {
+		return 0, false
+	}
+
+	discriminant := b*b - a*k
+	if discriminant < 0 {
+		return 0, false
+	}
+
+	// As p lies outside the circle, both roots have the same sign, and the
+	// circle lies behind the ray if the roots are negative.
+	t := (-b - math.Sqrt(discriminant)) / a
+	if t < 0 {
+		return 0, false
+	}
+	return t, true
+}
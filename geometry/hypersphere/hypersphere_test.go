@@ -0,0 +1,40 @@
+package hypersphere
+
+import (
+	"testing"
+
+	"github.com/downflux/go-geometry/2d/hypersphere"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+func TestIntersectRay(t *testing.T) {
+	s := *hypersphere.New(vector.V{10, 0}, 2)
+
+	type config struct {
+		name string
+		p    vector.V
+		d    vector.V
+		ok   bool
+		want float64
+	}
+
+	configs := []config{
+		{name: "Hit", p: vector.V{0, 0}, d: vector.V{1, 0}, ok: true, want: 8},
+		{name: "Hit/Scaled", p: vector.V{0, 0}, d: vector.V{2, 0}, ok: true, want: 4},
+		{name: "Hit/Tangent", p: vector.V{0, 2}, d: vector.V{1, 0}, ok: true, want: 10},
+		{name: "Inside", p: vector.V{11, 0}, d: vector.V{1, 0}, ok: true, want: 0},
+		{name: "Miss/Behind", p: vector.V{0, 0}, d: vector.V{-1, 0}, ok: false},
+		{name: "Miss/Offset", p: vector.V{0, 3}, d: vector.V{1, 0}, ok: false},
+		{name: "Miss/Zero", p: vector.V{0, 0}, d: vector.V{0, 0}, ok: false},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := IntersectRay(s, c.p, c.d)
+			if ok != c.ok || (ok && !epsilon.Within(got, c.want)) {
+				t.Errorf("IntersectRay() = %v, %v, want = %v, %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
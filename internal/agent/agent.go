@@ -91,6 +91,25 @@ func New(o O) *A {
 	return a
 }
 
+// Clone returns a deep copy of the agent.
+func (a *A) Clone() *A {
+	b := *a
+
+	b.position = vector.M{0, 0}
+	b.targetPosition = vector.M{0, 0}
+	b.velocity = vector.M{0, 0}
+	b.targetVelocity = vector.M{0, 0}
+	b.heading = polar.M{0, 0}
+
+	b.position.Copy(a.position.V())
+	b.targetPosition.Copy(a.targetPosition.V())
+	b.velocity.Copy(a.velocity.V())
+	b.targetVelocity.Copy(a.targetVelocity.V())
+	b.heading.Copy(a.heading.V())
+
+	return &b
+}
+
 func (a *A) ID() id.ID                   { return a.id }
 func (a *A) Flags() flags.F              { return a.flags }
 func (a *A) Team() team.F                { return a.team }
@@ -119,6 +138,20 @@ func (a *A) SetVelocity(v vector.V)       { a.velocity.Copy(v) }
 func (a *A) SetTargetVelocity(v vector.V) { a.targetVelocity.Copy(v) }
 func (a *A) SetHeading(v polar.V)         { a.heading.Copy(v) }
 
+// SetFlags sets the terrain flags of the agent. The flags are validated as at
+// creation time; layer transitions additionally check flags.ValidateStrict
+// before calling SetFlags.
+func (a *A) SetFlags(f flags.F) {
+	if !flags.Validate(f) {
+		panic(fmt.Sprintf("invalid flags: %v", f))
+	}
+	a.flags = f
+}
+
+// RestoreFlags sets the terrain flags of the agent without validation, e.g. to
+// revert a failed transaction to the flags the agent had before.
+func (a *A) RestoreFlags(f flags.F) { a.flags = f }
+
 func (a *A) SetMoveMode(f move.F) {
 	if !move.Validate(f) {
 		panic(fmt.Sprintf("invalid move mode: %v", f))
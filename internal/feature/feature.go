@@ -1,6 +1,8 @@
 package feature
 
 import (
+	"fmt"
+
 	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-database/flags"
 	"github.com/downflux/go-database/flags/team"
@@ -38,11 +40,30 @@ func New(o O) *F {
 	return f
 }
 
+// Clone returns a deep copy of the feature.
+func (f *F) Clone() *F {
+	g := *f
+
+	g.aabb = hnd.New(vnd.V{0, 0}, vnd.V{0, 0}).M()
+	g.aabb.Copy(f.aabb.R())
+
+	return &g
+}
+
 func (f *F) ID() id.ID              { return f.id }
 func (f *F) Flags() flags.F         { return f.flags }
 func (f *F) Team() team.F           { return f.team }
 func (f *F) AABB() hyperrectangle.R { return hyperrectangle.R(f.aabb.R()) }
 
-func (f *F) SetID(x id.ID) { f.id = x }
+func (f *F) SetID(x id.ID)                 { f.id = x }
+func (f *F) SetAABB(aabb hyperrectangle.R) { f.aabb.Copy(hnd.R(aabb)) }
+func (f *F) SetTeam(t team.F)              { f.team = t }
+
+func (f *F) SetFlags(g flags.F) {
+	if !flags.Validate(g) {
+		panic(fmt.Sprintf("invalid flags: %v", g))
+	}
+	f.flags = g
+}
 
 func Validate(o O) bool { return flags.Validate(o.Flags) }
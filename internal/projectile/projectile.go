@@ -57,6 +57,25 @@ func New(o O) *P {
 	return p
 }
 
+// Clone returns a deep copy of the projectile.
+func (p *P) Clone() *P {
+	q := *p
+
+	q.position = vector.M{0, 0}
+	q.targetPosition = vector.M{0, 0}
+	q.velocity = vector.M{0, 0}
+	q.targetVelocity = vector.M{0, 0}
+	q.heading = polar.M{0, 0}
+
+	q.position.Copy(p.position.V())
+	q.targetPosition.Copy(p.targetPosition.V())
+	q.velocity.Copy(p.velocity.V())
+	q.targetVelocity.Copy(p.targetVelocity.V())
+	q.heading.Copy(p.heading.V())
+
+	return &q
+}
+
 func (p *P) ID() id.ID                { return p.id }
 func (p *P) Flags() flags.F           { return p.flags }
 func (p *P) Team() team.F             { return p.team }
@@ -0,0 +1,100 @@
+// Package kinematics implements a fixed-timestep integrator for agents, which
+// respects the per-agent acceleration, velocity, and angular velocity limits.
+package kinematics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+// S is the kinematic state of an agent.
+type S struct {
+	Position vector.V
+	Velocity vector.V
+	Heading  polar.V
+}
+
+// Step calculates the kinematic state of the agent after a timestep of dt.
+//
+//  1. The velocity is accelerated towards the target velocity, where the
+//     magnitude of the acceleration is clamped by the max acceleration.
+//  1. The magnitude of the resultant velocity is clamped by the max velocity.
+//  1. The heading is rotated towards the direction of the velocity, where the
+//     rotation is clamped by the max angular velocity. The heading is not
+//     changed if the agent is not moving.
+//  1. The position is advanced by the new velocity.
+//
+// Step does not mutate the agent.
+func Step(a agent.RO, dt float64) S {
+	v := vector.M{0, 0}
+	v.Copy(a.Velocity())
+
+	dv := vector.M{0, 0}
+	dv.Copy(a.TargetVelocity())
+	dv.Sub(a.Velocity())
+	if dmax := a.MaxAcceleration() * dt; vector.SquaredMagnitude(dv.V()) > dmax*dmax {
+		dv.Unit()
+		dv.Scale(dmax)
+	}
+	v.Add(dv.V())
+
+	if vmax := a.MaxVelocity(); vector.SquaredMagnitude(v.V()) > vmax*vmax {
+		v.Unit()
+		v.Scale(vmax)
+	}
+
+	h := polar.V{1, a.Heading().Theta()}
+	if vector.SquaredMagnitude(v.V()) > 0 {
+		// Find the smallest signed rotation from the current heading
+		// to the velocity, in the range [-π, π).
+		dtheta := math.Atan2(v.Y(), v.X()) - h.Theta()
+		dtheta = math.Mod(dtheta+math.Pi, 2*math.Pi)
+		if dtheta < 0 {
+			dtheta += 2 * math.Pi
+		}
+		dtheta -= math.Pi
+
+		wmax := a.MaxAngularVelocity() * dt
+		dtheta = math.Max(-wmax, math.Min(wmax, dtheta))
+
+		h = polar.Normalize(polar.V{1, h.Theta() + dtheta})
+	}
+
+	return S{
+		Position: vector.Add(a.Position(), vector.Scale(dt, v.V())),
+		Velocity: v.V(),
+		Heading:  h,
+	}
+}
+
+// Tick advances all agents in the DB by a timestep of dt. All states are
+// calculated from the current state of the DB before any changes are
+// committed, and changes are committed in agent ID order, so the result does
+// not depend on the iteration order of the DB.
+//
+// Tick mutates the BVH and must be called serially.
+func Tick(db *database.DB, dt float64) {
+	type proposal struct {
+		x id.ID
+		s S
+	}
+
+	var proposals []proposal
+	db.ForEachAgent(func(a agent.RO) bool {
+		proposals = append(proposals, proposal{x: a.ID(), s: Step(a, dt)})
+		return true
+	})
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].x < proposals[j].x })
+
+	for _, p := range proposals {
+		db.SetAgentVelocity(p.x, p.s.Velocity)
+		db.SetAgentHeading(p.x, p.s.Heading)
+		db.SetAgentPosition(p.x, p.s.Position)
+	}
+}
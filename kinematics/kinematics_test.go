@@ -0,0 +1,148 @@
+package kinematics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/agent/mock"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestStep(t *testing.T) {
+	type config struct {
+		name string
+		a    agent.RO
+		dt   float64
+		want S
+	}
+
+	configs := []config{
+		{
+			name: "Stationary",
+			a: mock.New(1, agent.O{
+				Heading:            polar.V{1, math.Pi / 2},
+				MaxVelocity:        10,
+				MaxAcceleration:    1,
+				MaxAngularVelocity: 1,
+			}),
+			dt: 1,
+			want: S{
+				Position: vector.V{0, 0},
+				Velocity: vector.V{0, 0},
+				Heading:  polar.V{1, math.Pi / 2},
+			},
+		},
+		{
+			name: "Accelerate/Clamped",
+			a: mock.New(1, agent.O{
+				TargetVelocity:     vector.V{10, 0},
+				Heading:            polar.V{1, 0},
+				MaxVelocity:        10,
+				MaxAcceleration:    2,
+				MaxAngularVelocity: 1,
+			}),
+			dt: 0.5,
+			want: S{
+				Position: vector.V{0.5, 0},
+				Velocity: vector.V{1, 0},
+				Heading:  polar.V{1, 0},
+			},
+		},
+		{
+			name: "Velocity/Clamped",
+			a: mock.New(1, agent.O{
+				Velocity:           vector.V{9, 0},
+				TargetVelocity:     vector.V{20, 0},
+				Heading:            polar.V{1, 0},
+				MaxVelocity:        10,
+				MaxAcceleration:    5,
+				MaxAngularVelocity: 1,
+			}),
+			dt: 1,
+			want: S{
+				Position: vector.V{10, 0},
+				Velocity: vector.V{10, 0},
+				Heading:  polar.V{1, 0},
+			},
+		},
+		{
+			name: "Turn/Clamped",
+			a: mock.New(1, agent.O{
+				Velocity:           vector.V{0, 1},
+				TargetVelocity:     vector.V{0, 1},
+				Heading:            polar.V{1, 0},
+				MaxVelocity:        10,
+				MaxAcceleration:    1,
+				MaxAngularVelocity: math.Pi / 4,
+			}),
+			dt: 1,
+			want: S{
+				Position: vector.V{0, 1},
+				Velocity: vector.V{0, 1},
+				Heading:  polar.V{1, math.Pi / 4},
+			},
+		},
+		{
+			// The shortest rotation from θ = π / 4 to θ = -π / 4 is
+			// clockwise.
+			name: "Turn/Clockwise",
+			a: mock.New(1, agent.O{
+				Velocity:           vector.V{1, -1},
+				TargetVelocity:     vector.V{1, -1},
+				Heading:            polar.V{1, math.Pi / 4},
+				MaxVelocity:        10,
+				MaxAcceleration:    1,
+				MaxAngularVelocity: math.Pi,
+			}),
+			dt: 1,
+			want: S{
+				Position: vector.V{1, -1},
+				Velocity: vector.V{1, -1},
+				Heading:  polar.V{1, 7 * math.Pi / 4},
+			},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			got := Step(c.a, c.dt)
+			if !vector.Within(got.Position, c.want.Position) || !vector.Within(got.Velocity, c.want.Velocity) || !polar.Within(got.Heading, c.want.Heading) {
+				t.Errorf("Step() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTick(t *testing.T) {
+	db := database.New(database.DefaultO)
+	a := db.InsertAgent(agent.O{
+		Position:           vector.V{0, 0},
+		TargetPosition:     vector.V{0, 0},
+		Velocity:           vector.V{0, 0},
+		TargetVelocity:     vector.V{1, 0},
+		Heading:            polar.V{1, 0},
+		Radius:             1,
+		Mass:               1,
+		MaxVelocity:        1,
+		MaxAcceleration:    1,
+		MaxAngularVelocity: 1,
+		Size:               size.FSmall,
+	})
+
+	for i := 0; i < 10; i++ {
+		Tick(db, 1)
+	}
+
+	if want := (vector.V{10, 0}); !vector.Within(a.Position(), want) {
+		t.Errorf("Position() = %v, want = %v", a.Position(), want)
+	}
+	q := *hyperrectangle.New(vector.V{9.5, -0.5}, vector.V{10.5, 0.5})
+	if got := db.QueryAgents(q, func(agent.RO) bool { return true }); len(got) != 1 {
+		t.Errorf("QueryAgents() = %v, want = [%v]", got, a)
+	}
+}
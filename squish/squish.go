@@ -0,0 +1,69 @@
+// Package squish detects and optionally removes agents which are run over by
+// larger enemy agents, e.g. infantry crushed by a tank.
+package squish
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/filters"
+)
+
+// E is a squish event, where the victim agent is run over by the crusher.
+type E struct {
+	Crusher agent.RO
+	Victim  agent.RO
+}
+
+type O struct {
+	// Delete removes the victims from the DB.
+	Delete bool
+}
+
+// IsSquishing checks if either agent may run over the other, and the agents are
+// physically overlapping.
+func IsSquishing(a agent.RO, b agent.RO) bool {
+	return (filters.AgentIsSquishable(a, b) || filters.AgentIsSquishable(b, a)) && filters.AgentIsColliding(a, b)
+}
+
+// Detect finds all agents which are being run over, as defined by
+// filters.AgentIsSquishable. If multiple agents run over the same victim, only
+// the crusher with the smallest ID is reported. Events are sorted by victim ID.
+//
+// Detect is a read-only operation and may be called concurrently with other
+// read-only operations.
+func Detect(db *database.DB) []E {
+	events := map[id.ID]E{}
+	for _, p := range db.AgentPairs(IsSquishing) {
+		e := E{Crusher: p.B, Victim: p.A}
+		if filters.AgentIsSquishable(p.B, p.A) {
+			e = E{Crusher: p.A, Victim: p.B}
+		}
+		if f, ok := events[e.Victim.ID()]; !ok || e.Crusher.ID() < f.Crusher.ID() {
+			events[e.Victim.ID()] = e
+		}
+	}
+
+	results := make([]E, 0, len(events))
+	for _, e := range events {
+		results = append(results, e)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Victim.ID() < results[j].Victim.ID() })
+	return results
+}
+
+// Tick detects all squish events, and deletes the victims from the DB if
+// specified by the input options.
+//
+// Tick mutates the DB and must be called serially.
+func Tick(db *database.DB, o O) []E {
+	events := Detect(db)
+	if o.Delete {
+		for _, e := range events {
+			db.DeleteAgent(e.Victim.ID())
+		}
+	}
+	return events
+}
@@ -0,0 +1,61 @@
+package squish
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	dberrors "github.com/downflux/go-database/errors"
+)
+
+func TestTick(t *testing.T) {
+	db := database.New(database.DefaultO)
+
+	insert := func(p vector.V, s size.F, k team.F) agent.RO {
+		return db.InsertAgent(agent.O{
+			Position:       p,
+			TargetPosition: vector.V{0, 0},
+			Velocity:       vector.V{0, 0},
+			TargetVelocity: vector.V{0, 0},
+			Heading:        polar.V{1, 0},
+			Radius:         1,
+			Mass:           1,
+			Size:           s,
+			Team:           k,
+			Flags:          flags.FTerrainAccessibleLand | flags.FTerrainLand,
+		})
+	}
+
+	tank := insert(vector.V{0, 0}, size.FLarge, 1)
+	infantry := insert(vector.V{1, 0}, size.FSmall, 2)
+	ally := insert(vector.V{-1, 0}, size.FSmall, 1)
+	far := insert(vector.V{100, 0}, size.FSmall, 2)
+
+	events := Tick(db, O{Delete: true})
+
+	var got [][2]id.ID
+	for _, e := range events {
+		got = append(got, [2]id.ID{e.Crusher.ID(), e.Victim.ID()})
+	}
+	if want := [][2]id.ID{{tank.ID(), infantry.ID()}}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Tick() = %v, want = %v", got, want)
+	}
+
+	if _, err := db.GetAgent(infantry.ID()); !errors.Is(err, dberrors.ErrNotFound) {
+		t.Errorf("GetAgent() = _, %v, want = _, %v", err, dberrors.ErrNotFound)
+	}
+	for _, a := range []agent.RO{tank, ally, far} {
+		if _, err := db.GetAgent(a.ID()); err != nil {
+			t.Errorf("GetAgent() = _, %v, want = _, nil", err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package steering calculates the target velocity of agents from their move
+// mode, target position, and surroundings.
+//
+// See https://www.red3d.com/cwr/steer/gdc99/ for more information.
+package steering
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/filters"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+)
+
+var (
+	DefaultO = O{
+		Seek:       1,
+		Arrival:    1,
+		Avoidance:  2,
+		Alignment:  0.5,
+		Coherence:  0.5,
+		Separation: 1.5,
+
+		NeighborRadius:  5,
+		ArrivalRadius:   5,
+		AvoidanceRadius: 2,
+	}
+)
+
+// O defines the relative weight of each behavior, as well as the distance
+// parameters of the behaviors. Each behavior calculates a desired velocity
+// whose magnitude is at most the max velocity of the agent; the target
+// velocity is then the weighted sum of the desired velocities of all
+// behaviors enabled on the agent's move mode, clamped to the max velocity.
+type O struct {
+	Seek       float64
+	Arrival    float64
+	Avoidance  float64
+	Alignment  float64
+	Coherence  float64
+	Separation float64
+
+	// NeighborRadius is the distance from the agent's edge within which
+	// other agents are considered for the flocking behaviors.
+	NeighborRadius float64
+
+	// ArrivalRadius is the distance from the target position within
+	// which arriving agents start slowing down.
+	ArrivalRadius float64
+
+	// AvoidanceRadius is the distance from the agent's edge within which
+	// features are avoided.
+	AvoidanceRadius float64
+}
+
+// Steer calculates the target velocity of the input agent.
+func Steer(db database.RO, a agent.RO, o O) vector.V {
+	f := a.MoveMode()
+	v := vector.M{0, 0}
+
+	if f&move.FSeek == move.FSeek {
+		v.Add(vector.Scale(o.Seek, Seek(a)))
+	}
+	if f&move.FArrival == move.FArrival {
+		v.Add(vector.Scale(o.Arrival, Arrival(a, o.ArrivalRadius)))
+	}
+	if f&move.FAvoidance == move.FAvoidance {
+		v.Add(vector.Scale(o.Avoidance, Avoidance(a, db.QueryFeatures(
+			bound(a.Position(), a.Radius()+o.AvoidanceRadius),
+			func(g feature.RO) bool { return !filters.FeatureOnDifferentLayers(a, g) },
+		), o.AvoidanceRadius)))
+	}
+
+	if f&(move.FAlignment|move.FCoherence|move.FSeparation) != move.FNone {
+		neighbors := db.QueryAgentsInRadius(a.Position(), a.Radius()+o.NeighborRadius, func(b agent.RO) bool {
+			return a.ID() != b.ID() && !filters.AgentOnDifferentLayers(a, b)
+		})
+		// Sort the neighbors to ensure floating point sums are
+		// deterministic.
+		sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].ID() < neighbors[j].ID() })
+
+		if f&move.FSeparation == move.FSeparation {
+			v.Add(vector.Scale(o.Separation, Separation(a, neighbors, o.NeighborRadius)))
+		}
+
+		var allies []agent.RO
+		for _, b := range neighbors {
+			if filters.AgentIsAlly(a, b) {
+				allies = append(allies, b)
+			}
+		}
+		if f&move.FAlignment == move.FAlignment {
+			v.Add(vector.Scale(o.Alignment, Alignment(a, allies)))
+		}
+		if f&move.FCoherence == move.FCoherence {
+			v.Add(vector.Scale(o.Coherence, Coherence(a, allies)))
+		}
+	}
+
+	return clamp(v.V(), a.MaxVelocity())
+}
+
+// Tick sets the target velocity of all agents in the DB. All target velocities
+// are calculated from the current state of the DB before any changes are
+// committed.
+//
+// Tick does not mutate the BVH, but must not be called concurrently with
+// other calls which mutate agents.
+func Tick(db *database.DB, o O) {
+	var agents []agent.RO
+	db.ForEachAgent(func(a agent.RO) bool {
+		agents = append(agents, a)
+		return true
+	})
+
+	vs := make(map[id.ID]vector.V, len(agents))
+	for _, a := range agents {
+		vs[a.ID()] = Steer(db, a, o)
+	}
+	for x, v := range vs {
+		db.SetAgentTargetVelocity(x, v)
+	}
+}
+
+// Seek returns a velocity at max speed towards the agent's target position.
+func Seek(a agent.RO) vector.V {
+	return towards(a.Position(), a.TargetPosition(), a.MaxVelocity())
+}
+
+// Arrival returns a velocity towards the agent's target position, which slows
+// down linearly once the agent is within the arrival radius r of the target.
+func Arrival(a agent.RO, r float64) vector.V {
+	d := vector.Magnitude(vector.Sub(a.TargetPosition(), a.Position()))
+	if r <= 0 {
+		return Seek(a)
+	}
+	return towards(a.Position(), a.TargetPosition(), a.MaxVelocity()*math.Min(1, d/r))
+}
+
+// Avoidance returns a velocity which pushes the agent away from nearby
+// features, along the normal of the closest edge of each feature. Features
+// are weighted linearly by how close they are to the edge of the agent, up to
+// the avoidance radius r.
+func Avoidance(a agent.RO, features []feature.RO, r float64) vector.V {
+	v := vector.M{0, 0}
+	for _, f := range features {
+		if f.AABB().In(a.Position()) {
+			continue
+		}
+		d, n := dhr.Normal(f.AABB(), a.Position())
+		if d -= a.Radius(); d < r {
+			v.Add(vector.Scale(1-math.Max(0, d)/r, n))
+		}
+	}
+	return clamp(vector.Scale(a.MaxVelocity(), v.V()), a.MaxVelocity())
+}
+
+// Separation returns a velocity which pushes the agent away from its
+// neighbors. Neighbors are weighted linearly by how close they are to the edge
+// of the agent, up to the neighbor radius r.
+func Separation(a agent.RO, neighbors []agent.RO, r float64) vector.V {
+	v := vector.M{0, 0}
+	for _, b := range neighbors {
+		u := vector.Sub(a.Position(), b.Position())
+		m := vector.Magnitude(u)
+		if m == 0 {
+			continue
+		}
+		d := m - a.Radius() - b.Radius()
+		if d < r {
+			v.Add(vector.Scale((1-math.Max(0, d)/r)/m, u))
+		}
+	}
+	return clamp(vector.Scale(a.MaxVelocity(), v.V()), a.MaxVelocity())
+}
+
+// Alignment returns the average velocity of the neighbors.
+func Alignment(a agent.RO, neighbors []agent.RO) vector.V {
+	if len(neighbors) == 0 {
+		return vector.V{0, 0}
+	}
+
+	v := vector.M{0, 0}
+	for _, b := range neighbors {
+		v.Add(b.Velocity())
+	}
+	v.Scale(1 / float64(len(neighbors)))
+	return clamp(v.V(), a.MaxVelocity())
+}
+
+// Coherence returns a velocity at max speed towards the centroid of the
+// neighbors.
+func Coherence(a agent.RO, neighbors []agent.RO) vector.V {
+	if len(neighbors) == 0 {
+		return vector.V{0, 0}
+	}
+
+	c := vector.M{0, 0}
+	for _, b := range neighbors {
+		c.Add(b.Position())
+	}
+	c.Scale(1 / float64(len(neighbors)))
+	return towards(a.Position(), c.V(), a.MaxVelocity())
+}
+
+// towards returns a velocity of magnitude s pointing from p to q.
+func towards(p vector.V, q vector.V, s float64) vector.V {
+	u := vector.Sub(q, p)
+	if vector.SquaredMagnitude(u) == 0 {
+		return vector.V{0, 0}
+	}
+	return vector.Scale(s, vector.Unit(u))
+}
+
+func clamp(v vector.V, s float64) vector.V {
+	if vector.SquaredMagnitude(v) > s*s {
+		return vector.Scale(s, vector.Unit(v))
+	}
+	return v
+}
+
+func bound(p vector.V, r float64) hyperrectangle.R {
+	return *hyperrectangle.New(
+		vector.V{p.X() - r, p.Y() - r},
+		vector.V{p.X() + r, p.Y() + r},
+	)
+}
@@ -0,0 +1,149 @@
+package steering
+
+import (
+	"testing"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/agent/mock"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestArrival(t *testing.T) {
+	type config struct {
+		name string
+		a    agent.RO
+		r    float64
+		want vector.V
+	}
+
+	configs := []config{
+		{
+			name: "Far",
+			a: mock.New(1, agent.O{
+				TargetPosition: vector.V{10, 0},
+				MaxVelocity:    2,
+			}),
+			r:    5,
+			want: vector.V{2, 0},
+		},
+		{
+			name: "Near",
+			a: mock.New(1, agent.O{
+				TargetPosition: vector.V{0, 2.5},
+				MaxVelocity:    2,
+			}),
+			r:    5,
+			want: vector.V{0, 1},
+		},
+		{
+			name: "Arrived",
+			a: mock.New(1, agent.O{
+				MaxVelocity: 2,
+			}),
+			r:    5,
+			want: vector.V{0, 0},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Arrival(c.a, c.r); !vector.Within(got, c.want) {
+				t.Errorf("Arrival() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSeparation(t *testing.T) {
+	a := mock.New(1, agent.O{MaxVelocity: 10})
+
+	type config struct {
+		name      string
+		neighbors []agent.RO
+		want      vector.V
+	}
+
+	configs := []config{
+		{
+			name:      "None",
+			neighbors: nil,
+			want:      vector.V{0, 0},
+		},
+		{
+			// The neighbor is 1 unit past the edge of the agent,
+			// and therefore contributes half the max velocity.
+			name: "Single",
+			neighbors: []agent.RO{
+				mock.New(2, agent.O{Position: vector.V{3, 0}}),
+			},
+			want: vector.V{-5, 0},
+		},
+		{
+			name: "Symmetric",
+			neighbors: []agent.RO{
+				mock.New(2, agent.O{Position: vector.V{3, 0}}),
+				mock.New(3, agent.O{Position: vector.V{-3, 0}}),
+			},
+			want: vector.V{0, 0},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Separation(a, c.neighbors, 2); !vector.Within(got, c.want) {
+				t.Errorf("Separation() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTick(t *testing.T) {
+	db := database.New(database.DefaultO)
+	db.InsertFeature(feature.O{
+		AABB: *hyperrectangle.New(vector.V{-10, 2}, vector.V{10, 3}),
+	})
+
+	o := agent.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{10, 0},
+		Velocity:       vector.V{0, 0},
+		TargetVelocity: vector.V{0, 0},
+		Heading:        polar.V{1, 0},
+		Radius:         1,
+		Mass:           1,
+		MaxVelocity:    1,
+		Size:           size.FSmall,
+	}
+
+	o.Move = move.FNone
+	idle := db.InsertAgent(o)
+
+	o.Move = move.FSeek
+	seek := db.InsertAgent(o)
+
+	o.Move = move.FSeek | move.FAvoidance
+	avoid := db.InsertAgent(o)
+
+	// Only consider the feature for avoidance.
+	Tick(db, O{
+		Seek:            1,
+		Avoidance:       1,
+		AvoidanceRadius: 2,
+	})
+
+	if want := (vector.V{0, 0}); !vector.Within(idle.TargetVelocity(), want) {
+		t.Errorf("TargetVelocity() = %v, want = %v", idle.TargetVelocity(), want)
+	}
+	if want := (vector.V{1, 0}); !vector.Within(seek.TargetVelocity(), want) {
+		t.Errorf("TargetVelocity() = %v, want = %v", seek.TargetVelocity(), want)
+	}
+	if v := avoid.TargetVelocity(); v.Y() >= 0 || v.X() <= 0 {
+		t.Errorf("TargetVelocity() = %v, want a velocity away from the wall", v)
+	}
+}